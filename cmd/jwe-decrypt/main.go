@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwe-decrypt decrypts compact JWE lines from stdin with a private
+// key or a shared secret and writes the plaintext payloads, one per line.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+)
+
+// run parses CLI flags, loads the private key or secret, and decrypts
+// each input line. It returns a process exit code (0 on success, non-zero on error).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwe-decrypt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	keyFile := fs.String("key-file", "", "Path to private key (PEM PKCS1, SEC1 or PKCS8, JWK or JWK Set)")
+	secretFile := fs.String("secret-file", "", "Path to raw key for AES key wrap and dir, or password for PBES2")
+	withHeader := fs.Bool("header", false, `Write {"header":...,"payload":...} JSON objects including the protected header`)
+	pass := cli.RegisterPassphraseFlags(fs)
+	linesCfg := cli.RegisterLinesFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if (*keyFile == "") == (*secretFile == "") {
+		fmt.Fprintln(stderr, "exactly one of --key-file or --secret-file is required")
+		return 2
+	}
+
+	var d *encrypt.Decrypter
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "read key file:", err)
+			return 1
+		}
+		passphrase, err := pass.Passphrase()
+		if err != nil {
+			fmt.Fprintln(stderr, "read passphrase:", err)
+			return 1
+		}
+		d, err = encrypt.NewDecrypter(key, passphrase)
+		if err != nil {
+			fmt.Fprintln(stderr, "parse key:", err)
+			return 1
+		}
+	} else {
+		secret, err := os.ReadFile(*secretFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "read secret file:", err)
+			return 1
+		}
+		d, err = encrypt.NewSecretDecrypter(secret)
+		if err != nil {
+			fmt.Fprintln(stderr, "load secret:", err)
+			return 1
+		}
+	}
+
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := encrypt.DecryptLines(stdin, stdout, d, *withHeader, *linesCfg); err != nil {
+		fmt.Fprintln(stderr, "decrypt:", err)
+		cli.LinesSummary(stderr, linesCfg)
+		return 1
+	}
+	return cli.LinesSummary(stderr, linesCfg)
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(code)
+}
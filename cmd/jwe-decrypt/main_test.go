@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+)
+
+// writeRSAKeyPair writes a PKCS8 private key to dir and returns its path
+// with the PEM public key.
+func writeRSAKeyPair(t *testing.T, dir string) (string, []byte) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	path := filepath.Join(dir, "rsa.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func TestRunJweDecrypt_OK(t *testing.T) {
+	keyPath, pub := writeRSAKeyPair(t, t.TempDir())
+	var tokens bytes.Buffer
+	if err := encrypt.EncryptLinesRSAOAEP_A256GCM(strings.NewReader("{\"x\":1}\n{\"y\":2}\n"), &tokens, pub); err != nil {
+		t.Fatalf("EncryptLines: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath}, bytes.NewReader(tokens.Bytes()), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if out.String() != "{\"x\":1}\n{\"y\":2}\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	code = run([]string{"--key-file", keyPath, "-header"}, bytes.NewReader(tokens.Bytes()), &out, &errBuf)
+	if code != 0 || !strings.HasPrefix(out.String(), `{"header":{"alg":"RSA-OAEP","enc":"A256GCM"},"payload":{"x":1}}`) {
+		t.Fatalf("unexpected output (%d): %q", code, out.String())
+	}
+}
+
+func TestRunJweDecrypt_WrongKey(t *testing.T) {
+	keyPath, _ := writeRSAKeyPair(t, t.TempDir())
+	_, otherPub := writeRSAKeyPair(t, t.TempDir())
+	token, err := encrypt.EncryptRSAOAEP_A256GCM("p", otherPub)
+	if err != nil {
+		t.Fatalf("EncryptRSAOAEP_A256GCM: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath}, strings.NewReader(token+"\n"), &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "line 1: cannot decrypt the content encryption key (wrong key?)") {
+		t.Fatalf("expected wrong key error, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJweDecrypt_NoKeyFile(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "exactly one of --key-file or --secret-file is required") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJweDecrypt_SecretFile(t *testing.T) {
+	for _, tc := range []struct {
+		alg, enc, secret string
+	}{
+		{"dir", "A256GCM", "0123456789abcdef0123456789abcdef"},
+		{"A128KW", "A128CBC-HS256", "0123456789abcdef"},
+		{"A256GCMKW", "A256GCM", "0123456789abcdef0123456789abcdef"},
+		{"PBES2-HS256+A128KW", "A128GCM", "correct horse battery staple"},
+	} {
+		t.Run(tc.alg, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secret")
+			if err := os.WriteFile(path, []byte(tc.secret), 0o600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			e, err := encrypt.ParseEncrypter(tc.alg, []byte(tc.secret), encrypt.WithEnc(tc.enc))
+			if err != nil {
+				t.Fatalf("ParseEncrypter: %v", err)
+			}
+			token, err := e.Encrypt([]byte(`{"x":1}`))
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+
+			var out, errBuf bytes.Buffer
+			code := run([]string{"--secret-file", path}, strings.NewReader(token+"\n"), &out, &errBuf)
+			if code != 0 || out.String() != "{\"x\":1}\n" {
+				t.Fatalf("unexpected result %d: %q (stderr=%q)", code, out.String(), errBuf.String())
+			}
+		})
+	}
+}
+
+func TestRunJweDecrypt_KeyAndSecretFile(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", "k.pem", "--secret-file", "s.txt"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "exactly one of --key-file or --secret-file is required") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJweDecrypt_OnErrorSkip(t *testing.T) {
+	keyPath, pub := writeRSAKeyPair(t, t.TempDir())
+	var tokens bytes.Buffer
+	if err := encrypt.EncryptLinesRSAOAEP_A256GCM(strings.NewReader("{\"x\":1}\n{\"y\":2}\n"), &tokens, pub); err != nil {
+		t.Fatalf("EncryptLines: %v", err)
+	}
+	first, second, _ := strings.Cut(tokens.String(), "\n")
+	in := first + "\nbroken\n" + second
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath, "-on-error=skip", "-workers=2"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1 with a failed line, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if out.String() != "{\"x\":1}\n{\"y\":2}\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	want := "line 2: malformed JWE: expected 5 segments, got 1\nlines: 3 processed, 2 succeeded, 1 failed\n"
+	if errBuf.String() != want {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
 	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
 )
 
@@ -20,7 +21,8 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwe-encrypt-rsa-oaep-a256gcm", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	pubFile := fs.String("pub-key-file", "", "Path to RSA public key (PEM)")
+	pubFile := fs.String("pub-key-file", "", "Path to RSA public key (PEM PKIX or PKCS1)")
+	linesCfg := cli.RegisterLinesFlags(fs)
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
@@ -37,11 +39,14 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		return 1
 	}
 
-	if err := encrypt.EncryptLinesRSAOAEP_A256GCM(stdin, stdout, pub); err != nil {
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := encrypt.EncryptLinesRSAOAEP_A256GCM(stdin, stdout, pub, encrypt.WithLines(*linesCfg)); err != nil {
 		fmt.Fprintln(stderr, "encrypt:", err)
+		cli.LinesSummary(stderr, linesCfg)
 		return 1
 	}
-	return 0
+	return cli.LinesSummary(stderr, linesCfg)
 }
 
 // main is the entry point that delegates to run and exits with its status code.
@@ -57,3 +57,30 @@ func TestRunJweEncrypt_NoKeyFile(t *testing.T) {
 		t.Fatalf("unexpected stderr: %q", errBuf.String())
 	}
 }
+
+func TestRunJweEncrypt_LinesFlags(t *testing.T) {
+	pubPath := writeRSAPublicKeyPEM(t, t.TempDir())
+	in := "a\n" + strings.Repeat("x", 100) + "\nc\n"
+
+	var out, errBuf bytes.Buffer
+	args := []string{"--pub-key-file", pubPath, "-workers=2", "-max-line-bytes=50", "-on-error=emit"}
+	code := run(args, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1 with a failed line, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[1] != `{"line":2,"error":"100 bytes exceeds the maximum line size of 50 bytes"}` {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if errBuf.String() != "lines: 3 processed, 2 succeeded, 1 failed\n" {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	// The summary is written in the default fail mode too.
+	out.Reset()
+	errBuf.Reset()
+	code = run([]string{"--pub-key-file", pubPath, "-max-line-bytes=50"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 || !strings.HasSuffix(errBuf.String(), "lines: 2 processed, 1 succeeded, 1 failed\n") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwe-encrypt encrypts input lines into compact JWE with
+// selectable key management and content encryption algorithms.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+)
+
+// run parses CLI flags, loads the recipient key, and encrypts each input
+// line into a compact JWE. It returns a process exit code (0 on success,
+// non-zero on error).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwe-encrypt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	alg := fs.String("alg", "RSA-OAEP", "Key management algorithm: "+strings.Join(encrypt.KeyAlgorithms(), ", "))
+	enc := fs.String("enc", encrypt.DefaultEnc, "Content encryption algorithm: "+strings.Join(encrypt.ContentAlgorithms(), ", "))
+	keyFile := fs.String("key-file", "", "Path to recipient key: PEM public key, JWK or JWK Set for RSA and ECDH-ES; raw key for AES key wrap and dir; password for PBES2")
+	kid := fs.String("kid", "", "Key ID header, also selecting the key from a JWK Set")
+	zip := fs.Bool("zip", false, `DEFLATE-compress payloads before encryption and set the "zip":"DEF" header`)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
+	sizeReport := fs.Bool("size-report", false, "Print compressed vs. uncompressed token lengths to stderr (encrypts every payload twice)")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if *keyFile == "" {
+		fmt.Fprintln(stderr, "--key-file is required")
+		return 2
+	}
+
+	key, err := os.ReadFile(*keyFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "read key file:", err)
+		return 1
+	}
+	opts := []encrypt.Option{encrypt.WithEnc(*enc)}
+	if *kid != "" {
+		opts = append(opts, encrypt.WithHeader("kid", *kid))
+	}
+	if *zip {
+		opts = append(opts, encrypt.WithZip())
+	}
+	var stats encrypt.SizeStats
+	if *sizeReport {
+		opts = append(opts, encrypt.WithSizeStats(&stats))
+	}
+	e, err := encrypt.ParseEncrypter(*alg, key, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, "load key:", err)
+		return 1
+	}
+
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := encrypt.EncryptLines(stdin, stdout, e, *linesCfg); err != nil {
+		fmt.Fprintln(stderr, "encrypt:", err)
+		cli.LinesSummary(stderr, linesCfg)
+		return 1
+	}
+	if *sizeReport {
+		if err := stats.WriteReport(stderr); err != nil {
+			fmt.Fprintln(stderr, "write:", err)
+			return 1
+		}
+	}
+	return cli.LinesSummary(stderr, linesCfg)
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(code)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestRunJweEncrypt_ECDH(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	pubPath := writeFile(t, "ec.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", pubPath, "-alg=ECDH-ES+A256KW", "-kid=k1"}, strings.NewReader("{\"x\":1}\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	payload, hdr, err := jose.Decode(strings.TrimSpace(out.String()), priv)
+	if err != nil {
+		t.Fatalf("jose.Decode: %v", err)
+	}
+	if payload != `{"x":1}` || hdr["alg"] != "ECDH-ES+A256KW" || hdr["kid"] != "k1" {
+		t.Fatalf("unexpected token: %q %v", payload, hdr)
+	}
+}
+
+func TestRunJweEncrypt_Password(t *testing.T) {
+	passPath := writeFile(t, "pass.txt", []byte("correct horse"))
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", passPath, "-alg=PBES2-HS256+A128KW", "-enc=A128CBC-HS256"}, strings.NewReader("a\nb\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JWE tokens, got %d", len(lines))
+	}
+	payload, hdr, err := jose.Decode(lines[1], "correct horse")
+	if err != nil || payload != "b" || hdr["enc"] != "A128CBC-HS256" {
+		t.Fatalf("jose.Decode: %q, %v, %v", payload, hdr, err)
+	}
+}
+
+func TestRunJweEncrypt_ZipSizeReport(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keyPath := writeFile(t, "cek.bin", secret)
+	in := `{"groups":["` + strings.Repeat("developers,", 100) + `"]}` + "\n"
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath, "-alg=dir", "-zip", "-size-report"}, strings.NewReader(in), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	payload, hdr, err := jose.Decode(strings.TrimSpace(out.String()), secret)
+	if err != nil || payload != strings.TrimSpace(in) || hdr["zip"] != "DEF" {
+		t.Fatalf("jose.Decode: %v, %v", hdr, err)
+	}
+	for _, want := range []string{"tokens:       1\n", "uncompressed: ", "smaller with zip=DEF: 1 of 1 tokens\n"} {
+		if !strings.Contains(errBuf.String(), want) {
+			t.Fatalf("size report misses %q: %q", want, errBuf.String())
+		}
+	}
+}
+
+func TestRunJweEncrypt_KeyMismatch(t *testing.T) {
+	keyPath := writeFile(t, "kek.bin", []byte("0123456789abcdef"))
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath, "-alg=A256KW"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "A256KW requires a 256-bit key, got 128 bits") {
+		t.Fatalf("expected key size error, got %d (stderr=%q)", code, errBuf.String())
+	}
+
+	code = run([]string{"--key-file", keyPath, "-alg=RSA-OAEP-1024"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "unknown key management algorithm") {
+		t.Fatalf("expected unknown alg error, got %d (stderr=%q)", code, errBuf.String())
+	}
+
+	code = run([]string{"--key-file", keyPath, "-alg=A128KW", "-enc=A512GCM"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), `unknown content encryption algorithm: "A512GCM"`) {
+		t.Fatalf("expected unknown enc error, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJweEncrypt_NoKeyFile(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "--key-file is required") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJweEncrypt_OnErrorEmit(t *testing.T) {
+	passPath := writeFile(t, "pass.txt", []byte("correct horse"))
+	in := "a\n" + strings.Repeat("x", 100) + "\nc\n"
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", passPath, "-alg=PBES2-HS256+A128KW", "-max-line-bytes=50", "-on-error=emit"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1 with a failed line, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[1] != `{"line":2,"error":"100 bytes exceeds the maximum line size of 50 bytes"}` {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if payload, _, err := jose.Decode(lines[2], "correct horse"); err != nil || payload != "c" {
+		t.Fatalf("jose.Decode: %q, %v", payload, err)
+	}
+	if errBuf.String() != "lines: 3 processed, 2 succeeded, 1 failed\n" {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	errBuf.Reset()
+	if code := run([]string{"--key-file", passPath, "-on-error=ignore"}, strings.NewReader(in), &out, &errBuf); code != 2 {
+		t.Fatalf("expected 2 for unknown -on-error, got %d", code)
+	}
+}
+
+func TestRunJweEncrypt_Envelope(t *testing.T) {
+	passPath := writeFile(t, "pass.txt", []byte("correct horse"))
+	in := `{"header":{"kid":"k1"},"payload":{"sub":"a"}}` + "\n" + `{"header":{"enc":"A256GCM"},"payload":{}}` + "\n"
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", passPath, "-alg=PBES2-HS256+A128KW", "-input-format=jsonl-envelope", "-on-error=emit"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1 with a failed line, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `{"line":2,"error":`) {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	payload, hdr, err := jose.Decode(lines[0], "correct horse")
+	if err != nil || payload != `{"sub":"a"}` || hdr["kid"] != "k1" {
+		t.Fatalf("jose.Decode: %q, %v, %v", payload, hdr, err)
+	}
+}
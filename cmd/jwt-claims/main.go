@@ -3,6 +3,7 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"io"
@@ -11,8 +12,8 @@ import (
 	"github.com/danilkiff/jwt-token-generator/internal/claims"
 )
 
-// run parses CLI flags, builds a claims.Config, generates claims, and
-// writes them as JSONL to stdout. It returns a process exit code
+// run parses CLI flags, builds a claims.Config, and streams generated
+// claims as JSONL to stdout. It returns a process exit code
 // (0 on success, non-zero on error).
 func run(args []string, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwt-claims", flag.ContinueOnError)
@@ -24,11 +25,27 @@ func run(args []string, stdout, stderr io.Writer) int {
 	fixedIat := fs.Int64("iat", 0, "Fixed iat value (epoch seconds)")
 	useNow := fs.Bool("iat-now", false, "Use current time for iat")
 	seed := fs.Int64("seed", 0, "Random seed (0 => time-based)")
+	iss := fs.String("iss", "", "Issuer ('iss'), omitted when empty")
+	var aud []string
+	fs.Func("aud", "Audience ('aud'), repeat for several values", func(v string) error {
+		aud = append(aud, v)
+		return nil
+	})
+	expIn := fs.Duration("exp-in", 0, "Expiration ('exp') as offset from iat, e.g. 15m (0 => no exp)")
+	nbfSkew := fs.Duration("nbf-skew", 0, "Not-before ('nbf') as offset from iat, e.g. -30s (unset => no nbf)")
+	jti := fs.Bool("jti", false, "Add a unique 'jti' to every claim set")
+	tmplFile := fs.String("template", "", "Path to a JSON claims template with placeholders")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
 		return 2
 	}
+	useNbf := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "nbf-skew" {
+			useNbf = true
+		}
+	})
 
 	cfg := claims.Config{
 		Count:        *count,
@@ -37,19 +54,70 @@ func run(args []string, stdout, stderr io.Writer) int {
 		UseNowIat:    *useNow,
 		FixedIat:     *fixedIat,
 		Seed:         *seed,
+		Issuer:       *iss,
+		Audience:     aud,
+		ExpIn:        *expIn,
+		UseNbf:       useNbf,
+		NbfSkew:      *nbfSkew,
+		WithJTI:      *jti,
 	}
 
-	cs, err := claims.GenerateClaims(cfg)
+	if *tmplFile != "" {
+		return runTemplate(*tmplFile, cfg, stdout, stderr)
+	}
+
+	seq, err := claims.StreamClaims(cfg)
 	if err != nil {
 		fmt.Fprintln(stderr, "generate claims:", err)
 		return 1
 	}
-	data, err := claims.EncodeJSONLines(cs)
-	if err != nil {
+	bw := bufio.NewWriter(stdout)
+	if err := claims.WriteJSONLines(bw, seq); err != nil {
 		fmt.Fprintln(stderr, "encode:", err)
 		return 1
 	}
-	if _, err := stdout.Write(data); err != nil {
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintln(stderr, "write:", err)
+		return 1
+	}
+	return 0
+}
+
+// runTemplate renders cfg.Count claim sets from the template file and
+// writes them as JSONL to stdout as they are produced. It returns a
+// process exit code.
+func runTemplate(path string, cfg claims.Config, stdout, stderr io.Writer) int {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "read template:", err)
+		return 1
+	}
+	tmpl, err := claims.ParseTemplate(string(text))
+	if err != nil {
+		fmt.Fprintln(stderr, "parse template:", err)
+		return 1
+	}
+	seq, err := claims.StreamFromTemplate(tmpl, cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "generate claims:", err)
+		return 1
+	}
+	bw := bufio.NewWriter(stdout)
+	for c, err := range seq {
+		if err != nil {
+			fmt.Fprintln(stderr, "generate claims:", err)
+			return 1
+		}
+		if _, err := bw.Write(c); err != nil {
+			fmt.Fprintln(stderr, "write:", err)
+			return 1
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			fmt.Fprintln(stderr, "write:", err)
+			return 1
+		}
+	}
+	if err := bw.Flush(); err != nil {
 		fmt.Fprintln(stderr, "write:", err)
 		return 1
 	}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -18,6 +20,25 @@ func TestRunJwtClaimsOK(t *testing.T) {
 	}
 }
 
+func TestRunJwtClaimsRegistered(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	args := []string{
+		"-count=1", "-iat=1000", "-seed=1",
+		"-iss=issuer", "-aud=a", "-aud=b",
+		"-exp-in=15m", "-nbf-skew=0s", "-jti",
+	}
+	code := run(args, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	line := out.String()
+	for _, want := range []string{`"iss":"issuer"`, `"aud":["a","b"]`, `"exp":1900`, `"nbf":1000`, `"jti":"`} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("expected %s in %q", want, line)
+		}
+	}
+}
+
 func TestRunJwtClaimsInvalidConfig(t *testing.T) {
 	var out, errBuf bytes.Buffer
 	code := run([]string{"-count=0"}, &out, &errBuf)
@@ -28,3 +49,35 @@ func TestRunJwtClaimsInvalidConfig(t *testing.T) {
 		t.Fatalf("expected error about generate claims, got %q", errBuf.String())
 	}
 }
+
+func TestRunJwtClaimsTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "claims.tmpl.json")
+	tmpl := `{"tenant_id":"{{randString 8}}","n":{{seq}},"exp":{{now+1m}}}`
+	if err := os.WriteFile(path, []byte(tmpl), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-count=2", "-iat=100", "-seed=3", "-template", path}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[1], `"n":2,"exp":160`) {
+		t.Fatalf("unexpected line: %q", lines[1])
+	}
+}
+
+func TestRunJwtClaimsTemplateMissing(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-template", filepath.Join(t.TempDir(), "none.json")}, &out, &errBuf)
+	if code == 0 {
+		t.Fatalf("expected non-zero exit code for missing template")
+	}
+	if !strings.Contains(errBuf.String(), "read template") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwt-decode prints the header, payload and segment sizes of JWS
+// and JWE lines from stdin without verifying or decrypting them.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
+	"github.com/danilkiff/jwt-token-generator/internal/decode"
+)
+
+// run parses CLI flags and decodes each input line. It returns a process
+// exit code (0 on success, 1 when any token is malformed or on error, 2
+// on usage errors).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwt-decode", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	format := fs.String("format", decode.FormatJSONL, "Output format: "+decode.FormatJSONL+" or "+decode.FormatText+" (indented, human-readable)")
+	maxLineBytes := cli.RegisterMaxLineBytesFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if *format != decode.FormatJSONL && *format != decode.FormatText {
+		fmt.Fprintf(stderr, "unknown -format %q\n", *format)
+		return 2
+	}
+
+	bw := bufio.NewWriter(stdout)
+	malformed, err := decode.DecodeLines(stdin, bw, *format, time.Now(), *maxLineBytes)
+	// The lines decoded before an input error are written too.
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintln(stderr, "write:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "decode:", err)
+		return 1
+	}
+	if malformed > 0 {
+		fmt.Fprintf(stderr, "%d token(s) malformed\n", malformed)
+		return 1
+	}
+	return 0
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(code)
+}
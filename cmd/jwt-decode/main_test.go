@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestRunJwtDecode_OK(t *testing.T) {
+	token, err := sign.SignHS256(`{"sub":"a","exp":1700000900}`, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{}, strings.NewReader(token+"\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if !strings.Contains(out.String(), `"payload":{"sub":"a","exp":1700000900}`) {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	code = run([]string{"-format=text"}, strings.NewReader(token+"\n"), &out, &errBuf)
+	if code != 0 || !strings.Contains(out.String(), "exp: 2023-11-14T22:28:20Z (") {
+		t.Fatalf("unexpected text output (%d): %q", code, out.String())
+	}
+}
+
+func TestRunJwtDecode_ReadErrorKeepsOutput(t *testing.T) {
+	token, err := sign.SignHS256(`{"sub":"a"}`, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	in := io.MultiReader(strings.NewReader(token+"\n"), iotest.ErrReader(errors.New("broken pipe")))
+	code := run([]string{}, in, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "decode: broken pipe") {
+		t.Fatalf("expected read error, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if !strings.Contains(out.String(), `"payload":{"sub":"a"}`) {
+		t.Fatalf("expected the decoded line 1, got %q", out.String())
+	}
+}
+
+func TestRunJwtDecode_Unsecured(t *testing.T) {
+	// {"alg":"none"} with {"sub":"a"}, RFC 7519 section 6.
+	token := "eyJhbGciOiJub25lIn0.eyJzdWIiOiJhIn0."
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-format=text"}, strings.NewReader(token+"\n"), &out, &errBuf)
+	if code != 0 || !strings.Contains(out.String(), "signature: 0 bytes") {
+		t.Fatalf("expected 0 with an empty signature, got %d (stdout=%q, stderr=%q)", code, out.String(), errBuf.String())
+	}
+}
+
+func TestRunJwtDecode_Malformed(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{}, strings.NewReader("a.b\n"), &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "1 token(s) malformed") {
+		t.Fatalf("expected 1, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if !strings.Contains(out.String(), `"errors":["expected 3 (JWS) or 5 (JWE) segments, got 2"]`) {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestRunJwtDecode_BadFormat(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-format=yaml"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("expected 2, got %d", code)
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwt-keygen generates RSA, EC, Ed25519 and HMAC keys in the
+// formats accepted by the jwt-sign-* commands, and optionally writes the
+// public half as PEM and as JWK.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/keygen"
+)
+
+// run parses CLI flags, generates a key, and writes the private key to
+// -out (stdout by default) and the public half to -pub-out and -jwk-out.
+// It returns a process exit code (0 on success, non-zero on error).
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwt-keygen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	typ := fs.String("type", "", "Key type: rsa, ec, ed25519 or hmac")
+	bits := fs.Int("bits", 0, "Key size: 2048, 3072 or 4096 for rsa (default 2048); 256, 384 or 512 for hmac (default 256)")
+	curve := fs.String("curve", "P-256", "EC curve: P-256, P-384 or P-521")
+	out := fs.String("out", "", "Path to write the private key to (default stdout)")
+	pubOut := fs.String("pub-out", "", "Path to write the public key to (PEM, PKIX)")
+	jwkOut := fs.String("jwk-out", "", "Path to write the public key to (JWK, kid = RFC 7638 thumbprint)")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if *typ == "" {
+		fmt.Fprintln(stderr, "--type is required")
+		return 2
+	}
+	if *bits == 0 {
+		switch *typ {
+		case keygen.TypeRSA:
+			*bits = 2048
+		case keygen.TypeHMAC:
+			*bits = 256
+		}
+	}
+
+	key, err := keygen.Generate(*typ, *bits, *curve)
+	if err != nil {
+		fmt.Fprintln(stderr, "generate key:", err)
+		return 1
+	}
+
+	priv, err := keygen.MarshalPrivate(key)
+	if err != nil {
+		fmt.Fprintln(stderr, "encode private key:", err)
+		return 1
+	}
+	if *out == "" {
+		_, err = stdout.Write(priv)
+	} else {
+		err = os.WriteFile(*out, priv, 0o600)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "write private key:", err)
+		return 1
+	}
+
+	if *pubOut != "" {
+		pub, err := keygen.MarshalPublic(key)
+		if err != nil {
+			fmt.Fprintln(stderr, "encode public key:", err)
+			return 1
+		}
+		if err := os.WriteFile(*pubOut, pub, 0o644); err != nil {
+			fmt.Fprintln(stderr, "write public key:", err)
+			return 1
+		}
+	}
+
+	if *jwkOut != "" {
+		data, err := publicJWK(key)
+		if err != nil {
+			fmt.Fprintln(stderr, "encode JWK:", err)
+			return 1
+		}
+		if err := os.WriteFile(*jwkOut, data, 0o644); err != nil {
+			fmt.Fprintln(stderr, "write JWK:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// publicJWK returns the public JWK of key with its thumbprint as kid.
+// HMAC secrets have no public half and are rejected.
+func publicJWK(key any) ([]byte, error) {
+	if _, ok := key.([]byte); ok {
+		return nil, fmt.Errorf("HMAC secrets have no public half")
+	}
+	k, err := jwk.FromPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if k.Kid, err = k.Thumbprint(); err != nil {
+		return nil, err
+	}
+	k.Use = "sig"
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdout, os.Stderr)
+	os.Exit(code)
+}
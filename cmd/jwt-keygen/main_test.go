@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+func TestRunJwtKeygenEC(t *testing.T) {
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "es384.pem")
+	pubPath := filepath.Join(dir, "es384.pub.pem")
+	jwkPath := filepath.Join(dir, "es384.jwk")
+
+	var out, errBuf bytes.Buffer
+	args := []string{"-type=ec", "-curve=P-384", "-out", privPath, "-pub-out", pubPath, "-jwk-out", jwkPath}
+	code := run(args, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+
+	priv, err := os.ReadFile(privPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if _, err := sign.SignEC("{}", "ES384", priv); err != nil {
+		t.Fatalf("generated key must be accepted by SignEC: %v", err)
+	}
+	pub, err := os.ReadFile(pubPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !strings.HasPrefix(string(pub), "-----BEGIN PUBLIC KEY-----") {
+		t.Fatalf("unexpected public key: %s", pub)
+	}
+	data, err := os.ReadFile(jwkPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var k map[string]string
+	if err := json.Unmarshal(data, &k); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if k["kty"] != "EC" || k["crv"] != "P-384" || k["kid"] == "" || k["d"] != "" {
+		t.Fatalf("unexpected JWK: %v", k)
+	}
+}
+
+func TestRunJwtKeygenHMACToStdout(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"-type=hmac", "-bits=512"}, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	// 512 random bits take 86 base64url characters.
+	if out.Len() != 86 {
+		t.Fatalf("expected 86-byte secret, got %d", out.Len())
+	}
+	if _, err := sign.SignHMAC("{}", "HS512", out.Bytes()); err != nil {
+		t.Fatalf("generated secret must be accepted by SignHMAC: %v", err)
+	}
+}
+
+func TestRunJwtKeygenErrors(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	if code := run([]string{}, &out, &errBuf); code != 2 {
+		t.Fatalf("expected exit code 2 without -type, got %d", code)
+	}
+	if !strings.Contains(errBuf.String(), "--type is required") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	errBuf.Reset()
+	if code := run([]string{"-type=rsa", "-bits=1024"}, &out, &errBuf); code != 1 {
+		t.Fatalf("expected exit code 1 for 1024-bit RSA, got %d", code)
+	}
+
+	errBuf.Reset()
+	jwkPath := filepath.Join(t.TempDir(), "hmac.jwk")
+	if code := run([]string{"-type=hmac", "-jwk-out", jwkPath}, &out, &errBuf); code != 1 {
+		t.Fatalf("expected exit code 1 for HMAC JWK, got %d", code)
+	}
+}
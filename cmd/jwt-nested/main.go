@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwt-nested signs input lines with any signing algorithm and
+// encrypts the resulting JWS into a JWE with "cty":"JWT", producing nested
+// JWTs (RFC 7519 section 5.2).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+	"github.com/danilkiff/jwt-token-generator/internal/nested"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+// run parses CLI flags, loads the signing and recipient keys, and writes
+// one nested JWT per input line. It returns a process exit code (0 on
+// success, non-zero on error).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwt-nested", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	signAlg := fs.String("sign-alg", "RS256", "Signing algorithm: "+strings.Join(sign.Algorithms(), ", "))
+	signKeyFile := fs.String("sign-key-file", "", "Path to signing key (PEM private key, HMAC secret, JWK or JWK Set)")
+	headers := cli.RegisterHeaderFlags(fs)
+	pass := cli.RegisterPassphraseFlags(fs)
+	encAlg := fs.String("enc-alg", "RSA-OAEP", "Key management algorithm: "+strings.Join(encrypt.KeyAlgorithms(), ", "))
+	enc := fs.String("enc", encrypt.DefaultEnc, "Content encryption algorithm: "+strings.Join(encrypt.ContentAlgorithms(), ", "))
+	encKeyFile := fs.String("enc-key-file", "", "Path to recipient key (see jwe-encrypt -key-file)")
+	encKid := fs.String("enc-kid", "", "Key ID of the JWE header, also selecting the recipient key from a JWK Set")
+	zip := fs.Bool("zip", false, `DEFLATE-compress the JWS before encryption and set the "zip":"DEF" header`)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
+	replicate := fs.String("replicate", "", "Comma-separated claims copied into the JWE header, e.g. iss,sub,aud")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if *signKeyFile == "" || *encKeyFile == "" {
+		fmt.Fprintln(stderr, "--sign-key-file and --enc-key-file are required")
+		return 2
+	}
+
+	signKey, err := os.ReadFile(*signKeyFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "read key file:", err)
+		return 1
+	}
+	passOpts, err := pass.SignOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, "read passphrase:", err)
+		return 1
+	}
+	s, err := sign.ParseSigner(*signAlg, signKey, append(headers.SignOptions(), passOpts...)...)
+	if err != nil {
+		fmt.Fprintln(stderr, "load signing key:", err)
+		return 1
+	}
+
+	encKey, err := os.ReadFile(*encKeyFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "read key file:", err)
+		return 1
+	}
+	opts := []encrypt.Option{encrypt.WithEnc(*enc)}
+	if *encKid != "" {
+		opts = append(opts, encrypt.WithHeader("kid", *encKid))
+	}
+	if *zip {
+		opts = append(opts, encrypt.WithZip())
+	}
+	e, err := encrypt.ParseEncrypter(*encAlg, encKey, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, "load recipient key:", err)
+		return 1
+	}
+
+	var claims []string
+	if *replicate != "" {
+		claims = strings.Split(*replicate, ",")
+	}
+	n, err := nested.New(s, e, claims...)
+	if err != nil {
+		fmt.Fprintln(stderr, "replicate:", err)
+		return 2
+	}
+
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := nested.EncodeLines(stdin, stdout, n, *linesCfg); err != nil {
+		fmt.Fprintln(stderr, "nest:", err)
+		cli.LinesSummary(stderr, linesCfg)
+		return 1
+	}
+	return cli.LinesSummary(stderr, linesCfg)
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(code)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestRunJwtNested_OK(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+	signKey := writeFile(t, "es256.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	kek := []byte("0123456789abcdef0123456789abcdef")
+	encKey := writeFile(t, "kek.bin", kek)
+
+	args := []string{
+		"--sign-key-file", signKey, "-sign-alg=ES256", "-kid=sig-1",
+		"--enc-key-file", encKey, "-enc-alg=A256KW", "-enc=A128CBC-HS256", "-enc-kid=enc-1",
+		"-replicate=iss, sub, aud",
+	}
+	var out, errBuf bytes.Buffer
+	code := run(args, strings.NewReader(`{"iss":"partner","sub":"alice","exp":1700000900}`+"\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+
+	jws, hdr, err := jose.Decode(strings.TrimSpace(out.String()), kek)
+	if err != nil {
+		t.Fatalf("jose.Decode JWE: %v", err)
+	}
+	if hdr["cty"] != "JWT" || hdr["kid"] != "enc-1" || hdr["enc"] != "A128CBC-HS256" || hdr["iss"] != "partner" || hdr["sub"] != "alice" {
+		t.Fatalf("unexpected JWE header: %v", hdr)
+	}
+	payload, inner, err := jose.Decode(jws, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("jose.Decode JWS: %v", err)
+	}
+	if inner["alg"] != "ES256" || inner["kid"] != "sig-1" || !strings.Contains(payload, `"sub":"alice"`) {
+		t.Fatalf("unexpected inner token: %q %v", payload, inner)
+	}
+}
+
+func TestRunJwtNested_Errors(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--sign-key-file", "x"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "--sign-key-file and --enc-key-file are required") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+
+	secret := writeFile(t, "secret.txt", []byte("0123456789abcdef0123456789abcdef"))
+	errBuf.Reset()
+	code = run([]string{"--sign-key-file", secret, "-sign-alg=HS256", "--enc-key-file", secret, "-enc-alg=dir", "-replicate=enc"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), `claim "enc" cannot be replicated`) {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--sign-key-file", secret, "-sign-alg=HS256", "--enc-key-file", secret, "-enc-alg=dir", "-replicate=sub"}, strings.NewReader("plain\n"), &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "line 1: replicate claims: payload is not a JSON object") {
+		t.Fatalf("unexpected result %d (stderr=%q)", code, errBuf.String())
+	}
+
+	// With -on-error=skip the bad line is logged and the others are nested.
+	out.Reset()
+	errBuf.Reset()
+	code = run([]string{"--sign-key-file", secret, "-sign-alg=HS256", "--enc-key-file", secret, "-enc-alg=dir", "-replicate=sub", "-on-error=skip"},
+		strings.NewReader("{\"sub\":\"a\"}\nplain\n{\"sub\":\"b\"}\n"), &out, &errBuf)
+	if code != 1 || strings.Count(out.String(), "\n") != 2 {
+		t.Fatalf("unexpected result %d (stdout=%q)", code, out.String())
+	}
+	if !strings.HasPrefix(errBuf.String(), "line 2: replicate claims: payload is not a JSON object") || !strings.HasSuffix(errBuf.String(), "\nlines: 3 processed, 2 succeeded, 1 failed\n") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+func TestRunJwtNested_Envelope(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	key := writeFile(t, "secret.txt", secret)
+	args := []string{
+		"--sign-key-file", key, "-sign-alg=HS256", "--enc-key-file", key, "-enc-alg=A256KW",
+		"-input-format=jsonl-envelope", "-replicate=sub",
+	}
+	in := `{"header":{"kid":"sig-2"},"payload":{"sub":"bob"}}` + "\n"
+	var out, errBuf bytes.Buffer
+	if code := run(args, strings.NewReader(in), &out, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	jws, hdr, err := jose.Decode(strings.TrimSpace(out.String()), secret)
+	if err != nil || hdr["sub"] != "bob" {
+		t.Fatalf("jose.Decode JWE: %v, %v", hdr, err)
+	}
+	if _, inner, err := jose.Decode(jws, secret); err != nil || inner["kid"] != "sig-2" {
+		t.Fatalf("jose.Decode JWS: %v, %v", inner, err)
+	}
+}
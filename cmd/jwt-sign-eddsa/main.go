@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
 	"github.com/danilkiff/jwt-token-generator/internal/sign"
 )
 
@@ -19,12 +20,20 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwt-sign-eddsa", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	keyFile := fs.String("key-file", "", "Path to Ed25519 private key (PEM, PKCS8)")
+	keyFile := fs.String("key-file", "", "Path to Ed25519 private key (PEM PKCS8, JWK or JWK Set)")
+	headers := cli.RegisterHeaderFlags(fs)
+	pass := cli.RegisterPassphraseFlags(fs)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
 		return 2
 	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
 	if *keyFile == "" {
 		fmt.Fprintln(stderr, "--key-file is required")
 		return 2
@@ -35,12 +44,20 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		fmt.Fprintln(stderr, "read key file:", err)
 		return 1
 	}
+	passOpts, err := pass.SignOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, "read passphrase:", err)
+		return 1
+	}
 
-	if err := sign.SignLinesEdDSA(stdin, stdout, key); err != nil {
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := sign.SignLinesEdDSA(stdin, stdout, key, append(headers.SignOptions(), append(passOpts, sign.WithLines(*linesCfg))...)...); err != nil {
 		fmt.Fprintln(stderr, "sign:", err)
+		cli.LinesSummary(stderr, linesCfg)
 		return 1
 	}
-	return 0
+	return cli.LinesSummary(stderr, linesCfg)
 }
 
 // main is the entry point that delegates to run and exits with its status code.
@@ -5,6 +5,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"os"
 	"path/filepath"
@@ -57,3 +58,60 @@ func TestRunJwtSignEdDSA_NoKeyFile(t *testing.T) {
 		t.Fatalf("unexpected stderr: %q", errBuf.String())
 	}
 }
+
+func TestRunJwtSignEdDSA_Headers(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := writeEdPrivateKeyPEM(t, dir)
+
+	var out, errBuf bytes.Buffer
+	args := []string{"--key-file", keyPath, "-header", "kid=key-1", "-header", "typ=at+jwt", "-header-json", `{"n":1}`}
+	code := run(args, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	header, _, _ := strings.Cut(out.String(), ".")
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if string(data) != `{"alg":"EdDSA","kid":"key-1","n":1,"typ":"at+jwt"}` {
+		t.Fatalf("unexpected header: %s", data)
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--key-file", keyPath, "-header", "alg=none"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("expected exit code 2 for alg header, got %d", code)
+	}
+}
+
+func TestRunJwtSignEdDSA_ThumbprintKid(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := writeEdPrivateKeyPEM(t, dir)
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath, "-kid=thumbprint"}, strings.NewReader("{}\n{}\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	h0, _, _ := strings.Cut(lines[0], ".")
+	h1, _, _ := strings.Cut(lines[1], ".")
+	data, err := base64.RawURLEncoding.DecodeString(h0)
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if h0 != h1 || !strings.Contains(string(data), `"kid":"`) {
+		t.Fatalf("expected stable thumbprint kid, got %s", data)
+	}
+}
+
+func TestRunJwtSignEdDSA_ThumbprintKidConflict(t *testing.T) {
+	keyPath := writeEdPrivateKeyPEM(t, t.TempDir())
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath, "-kid=thumbprint", "-header", "kid=k1"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "a kid header is set already") {
+		t.Fatalf("expected failure for -kid=thumbprint with a kid header, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
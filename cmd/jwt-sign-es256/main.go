@@ -1,7 +1,8 @@
 // SPDX-License-Identifier: MIT
 
 // Command jwt-sign-es256 signs input lines with ES256 using
-// an EC private key (P-256, PEM).
+// an EC private key (P-256, PEM). Other ECDSA algorithms (ES384, ES512,
+// ES256K) can be selected with -alg; the key must be on the matching curve.
 package main
 
 import (
@@ -9,22 +10,34 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
 	"github.com/danilkiff/jwt-token-generator/internal/sign"
 )
 
 // run parses CLI flags, loads the EC private key, and signs each input line
-// with ES256. It returns a process exit code (0 on success, non-zero on error).
+// with the selected ECDSA algorithm (ES256 by default). It returns a
+// process exit code (0 on success, non-zero on error).
 func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwt-sign-es256", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	keyFile := fs.String("key-file", "", "Path to EC private key (PEM, P-256)")
+	keyFile := fs.String("key-file", "", "Path to EC private key (PEM SEC1 or PKCS8, JWK or JWK Set; curve matching -alg)")
+	alg := fs.String("alg", "ES256", "Signing algorithm: "+strings.Join(sign.AlgorithmsFor("EC"), ", "))
+	headers := cli.RegisterHeaderFlags(fs)
+	pass := cli.RegisterPassphraseFlags(fs)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
 		return 2
 	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
 	if *keyFile == "" {
 		fmt.Fprintln(stderr, "--key-file is required")
 		return 2
@@ -35,12 +48,20 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		fmt.Fprintln(stderr, "read key file:", err)
 		return 1
 	}
+	passOpts, err := pass.SignOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, "read passphrase:", err)
+		return 1
+	}
 
-	if err := sign.SignLinesES256(stdin, stdout, key); err != nil {
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := sign.SignLinesEC(stdin, stdout, *alg, key, append(headers.SignOptions(), append(passOpts, sign.WithLines(*linesCfg))...)...); err != nil {
 		fmt.Fprintln(stderr, "sign:", err)
+		cli.LinesSummary(stderr, linesCfg)
 		return 1
 	}
-	return 0
+	return cli.LinesSummary(stderr, linesCfg)
 }
 
 // main is the entry point that delegates to run and exits with its status code.
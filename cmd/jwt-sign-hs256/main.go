@@ -1,6 +1,8 @@
 // SPDX-License-Identifier: MIT
 
 // Command jwt-sign-hs256 signs input lines with HS256 using a shared secret.
+// HS384 and HS512 can be selected with -alg. Secrets shorter than the hash
+// output (RFC 7518 section 3.2) are rejected unless -allow-weak-key is set.
 package main
 
 import (
@@ -8,24 +10,35 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
 	"github.com/danilkiff/jwt-token-generator/internal/sign"
 )
 
-// run parses CLI flags, resolves the HS256 secret from a value or file,
-// and signs each non-empty input line with HS256. It returns a process
-// exit code.
+// run parses CLI flags, resolves the HMAC secret from a value or file,
+// and signs each non-empty input line with the selected HMAC algorithm.
+// It returns a process exit code.
 func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwt-sign-hs256", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	secretFile := fs.String("key-file", "", "Path to HS256 secret (text)")
-	secretStr := fs.String("key", "", "HS256 secret value")
+	secretFile := fs.String("key-file", "", "Path to HMAC secret (text, JWK or JWK Set)")
+	secretStr := fs.String("key", "", "HMAC secret value")
+	alg := fs.String("alg", "HS256", "Signing algorithm: "+strings.Join(sign.AlgorithmsFor("oct"), ", "))
+	allowWeak := fs.Bool("allow-weak-key", false, "Accept secrets shorter than the hash output (negative testing only)")
+	headers := cli.RegisterHeaderFlags(fs)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
 		return 2
 	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
 
 	var secret []byte
 	if *secretFile != "" {
@@ -42,11 +55,18 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		return 2
 	}
 
-	if err := sign.SignLinesHS256(stdin, stdout, secret); err != nil {
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	opts := append(headers.SignOptions(), sign.WithLines(*linesCfg))
+	if *allowWeak {
+		opts = append(opts, sign.AllowWeakKey())
+	}
+	if err := sign.SignLinesHMAC(stdin, stdout, *alg, secret, opts...); err != nil {
 		fmt.Fprintln(stderr, "sign:", err)
+		cli.LinesSummary(stderr, linesCfg)
 		return 1
 	}
-	return 0
+	return cli.LinesSummary(stderr, linesCfg)
 }
 
 // main is the entry point that delegates to run and exits with its status code.
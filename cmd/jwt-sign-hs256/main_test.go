@@ -2,14 +2,21 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 )
 
+// testSecret is a 512-bit HMAC secret, long enough for every HMAC algorithm.
+const testSecret = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
 func TestRunJwtSignHS256_OK(t *testing.T) {
 	var out, errBuf bytes.Buffer
 	in := strings.NewReader("{\"a\":1}\n{\"b\":2}\n")
-	code := run([]string{"--key=secret"}, in, &out, &errBuf)
+	code := run([]string{"--key=" + testSecret}, in, &out, &errBuf)
 	if code != 0 {
 		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
 	}
@@ -19,6 +26,23 @@ func TestRunJwtSignHS256_OK(t *testing.T) {
 	}
 }
 
+func TestRunJwtSignHS256_WeakKey(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key=secret", "-alg=HS512"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code == 0 {
+		t.Fatalf("expected non-zero exit for weak key")
+	}
+	if !strings.Contains(errBuf.String(), "at least 512 bits, got 48 bits") {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--key=secret", "-alg=HS512", "-allow-weak-key"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0 with -allow-weak-key, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
 func TestRunJwtSignHS256_NoKey(t *testing.T) {
 	var out, errBuf bytes.Buffer
 	in := strings.NewReader("{}\n")
@@ -30,3 +54,179 @@ func TestRunJwtSignHS256_NoKey(t *testing.T) {
 		t.Fatalf("unexpected stderr: %q", errBuf.String())
 	}
 }
+
+func TestRunJwtSignHS256_Workers(t *testing.T) {
+	var in strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&in, "{\"n\":%d}\n", i)
+	}
+
+	var sequential, errBuf bytes.Buffer
+	if code := run([]string{"--key=" + testSecret, "-workers=1"}, strings.NewReader(in.String()), &sequential, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	var parallel bytes.Buffer
+	if code := run([]string{"--key=" + testSecret, "-workers=8"}, strings.NewReader(in.String()), &parallel, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	// HS256 is deterministic, so ordered output must match byte for byte.
+	if parallel.String() != sequential.String() {
+		t.Fatalf("parallel output differs from sequential output")
+	}
+
+	var unordered bytes.Buffer
+	if code := run([]string{"--key=" + testSecret, "-workers=8", "-unordered"}, strings.NewReader(in.String()), &unordered, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	got := strings.Split(strings.TrimSpace(unordered.String()), "\n")
+	want := strings.Split(strings.TrimSpace(sequential.String()), "\n")
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unordered output has different tokens")
+	}
+}
+
+func TestRunJwtSignHS256_MaxLineBytes(t *testing.T) {
+	// A claim set larger than bufio.Scanner's default 64 KiB limit.
+	large := fmt.Sprintf("{\"groups\":[\"%s\"]}", strings.Repeat("g", 100<<10))
+	in := "{}\n" + large + "\n"
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"--key=" + testSecret}, strings.NewReader(in), &out, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Fatalf("expected 2 tokens, got %q", out.String()[:min(out.Len(), 80)])
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	code := run([]string{"--key=" + testSecret, "-max-line-bytes=65536"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1, got %d", code)
+	}
+	want := fmt.Sprintf("line 2: %d bytes exceeds the maximum line size of 65536 bytes", len(large))
+	if !strings.Contains(errBuf.String(), want) {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("expected the token for line 1, got %q", out.String())
+	}
+}
+
+func TestRunJwtSignHS256_OnErrorSkip(t *testing.T) {
+	in := "{}\n" + strings.Repeat("x", 100) + "\n{\"a\":1}\n"
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key=" + testSecret, "-max-line-bytes=50", "-on-error=skip"}, strings.NewReader(in), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1 with a failed line, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Fatalf("expected 2 tokens, got %q", out.String())
+	}
+	want := "line 2: 100 bytes exceeds the maximum line size of 50 bytes\nlines: 3 processed, 2 succeeded, 1 failed\n"
+	if errBuf.String() != want {
+		t.Fatalf("unexpected stderr: %q", errBuf.String())
+	}
+}
+
+// payloads returns the decoded payloads of the JWS lines in out.
+func payloads(t *testing.T, out string) []string {
+	t.Helper()
+	var got []string
+	for _, tok := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		parts := strings.Split(tok, ".")
+		if len(parts) != 3 {
+			t.Fatalf("not a JWS: %q", tok)
+		}
+		p, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		got = append(got, string(p))
+	}
+	return got
+}
+
+func TestRunJwtSignHS256_RawAndBase64(t *testing.T) {
+	in := " {\"a\": 1} \r\n\n\tx\n"
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{`{"a": 1}`, "x"}},
+		{[]string{"-raw"}, []string{` {"a": 1} `, "", "\tx"}},
+		{[]string{"-raw", "-crlf=keep"}, []string{" {\"a\": 1} \r", "", "\tx"}},
+	} {
+		var out, errBuf bytes.Buffer
+		if code := run(append([]string{"--key=" + testSecret}, tc.args...), strings.NewReader(in), &out, &errBuf); code != 0 {
+			t.Fatalf("%v: expected 0, got %d (stderr=%q)", tc.args, code, errBuf.String())
+		}
+		if got := payloads(t, out.String()); !slices.Equal(got, tc.want) {
+			t.Fatalf("%v: got payloads %q, want %q", tc.args, got, tc.want)
+		}
+	}
+
+	var out, errBuf bytes.Buffer
+	if code := run([]string{"--key=" + testSecret, "-base64"}, strings.NewReader("AP/+\n"), &out, &errBuf); code != 0 {
+		t.Fatalf("-base64: expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if got := payloads(t, out.String()); len(got) != 1 || got[0] != "\x00\xff\xfe" {
+		t.Fatalf("-base64: got payloads %q", got)
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	if code := run([]string{"--key=" + testSecret, "-crlf=keep"}, strings.NewReader(in), &out, &errBuf); code != 2 ||
+		!strings.Contains(errBuf.String(), "-crlf=keep requires -raw") {
+		t.Fatalf("-crlf=keep without -raw: expected usage error, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJwtSignHS256_InputFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format, in string
+		want       []string
+	}{
+		{"json-array", `[{"sub":"a"}, "text"]`, []string{`{"sub":"a"}`, "text"}},
+		{"csv", "sub,exp:number\na,1700000000\nb,\n", []string{`{"sub":"a","exp":1700000000}`, `{"sub":"b"}`}},
+		{"jsonl-envelope", `{"header":{"kid":"k1"},"payload":{"sub":"a"}}` + "\n", []string{`{"sub":"a"}`}},
+	} {
+		var out, errBuf bytes.Buffer
+		code := run([]string{"--key=" + testSecret, "-input-format=" + tc.format}, strings.NewReader(tc.in), &out, &errBuf)
+		if code != 0 {
+			t.Fatalf("%s: expected 0, got %d (stderr=%q)", tc.format, code, errBuf.String())
+		}
+		if got := payloads(t, out.String()); !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got payloads %q, want %q", tc.format, got, tc.want)
+		}
+	}
+
+	// Each envelope carries its own header.
+	var out, errBuf bytes.Buffer
+	in := `{"header":{"kid":"k1","typ":"at+jwt"},"payload":{}}` + "\n" + `{"payload":{}}` + "\n"
+	if code := run([]string{"--key=" + testSecret, "-kid=default", "-input-format=jsonl-envelope"}, strings.NewReader(in), &out, &errBuf); code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	tokens := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for i, want := range []string{`{"alg":"HS256","kid":"k1","typ":"at+jwt"}`, `{"alg":"HS256","kid":"default"}`} {
+		header, _, _ := strings.Cut(tokens[i], ".")
+		if got, _ := base64.RawURLEncoding.DecodeString(header); string(got) != want {
+			t.Fatalf("token %d: got header %s, want %s", i+1, got, want)
+		}
+	}
+
+	errBuf.Reset()
+	code := run([]string{"--key=" + testSecret, "-input-format=json-array"}, strings.NewReader(`{}`), &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "expected a JSON array") {
+		t.Fatalf("expected failure for non-array input, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
+
+func TestRunJwtSignHS256_ThumbprintKid(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key=" + testSecret, "-kid=thumbprint"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 1 || out.Len() != 0 || !strings.Contains(errBuf.String(), "not available for HMAC secrets") {
+		t.Fatalf("expected failure without tokens, got %d (stdout=%q, stderr=%q)", code, out.String(), errBuf.String())
+	}
+}
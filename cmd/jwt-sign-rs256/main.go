@@ -1,7 +1,8 @@
 // SPDX-License-Identifier: MIT
 
 // Command jwt-sign-rs256 signs input lines with RS256 using an RSA
-// private key in PEM format.
+// private key in PEM format. Other RSA algorithms (RS384, RS512, PS256,
+// PS384, PS512) can be selected with -alg.
 package main
 
 import (
@@ -9,23 +10,34 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
 	"github.com/danilkiff/jwt-token-generator/internal/sign"
 )
 
 // run parses CLI flags, loads the RSA private key, and signs each input
-// line with RS256. It returns a process exit code (0 on success, non-zero
-// on error).
+// line with the selected RSA algorithm (RS256 by default). It returns a
+// process exit code (0 on success, non-zero on error).
 func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	fs := flag.NewFlagSet("jwt-sign-rs256", flag.ContinueOnError)
 	fs.SetOutput(stderr)
 
-	keyFile := fs.String("key-file", "", "Path to RSA private key (PEM)")
+	keyFile := fs.String("key-file", "", "Path to RSA private key (PEM PKCS1 or PKCS8, JWK or JWK Set)")
+	alg := fs.String("alg", "RS256", "Signing algorithm: "+strings.Join(sign.AlgorithmsFor("RSA"), ", "))
+	headers := cli.RegisterHeaderFlags(fs)
+	pass := cli.RegisterPassphraseFlags(fs)
+	linesCfg := cli.RegisterLinesFlags(fs)
+	cli.RegisterPayloadFlags(fs, linesCfg)
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintln(stderr, "parse flags:", err)
 		return 2
 	}
+	if err := cli.CheckPayloadFlags(linesCfg); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
 	if *keyFile == "" {
 		fmt.Fprintln(stderr, "--key-file is required")
 		return 2
@@ -36,12 +48,20 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		fmt.Fprintln(stderr, "read key file:", err)
 		return 1
 	}
+	passOpts, err := pass.SignOptions()
+	if err != nil {
+		fmt.Fprintln(stderr, "read passphrase:", err)
+		return 1
+	}
 
-	if err := sign.SignLinesRS256(stdin, stdout, key); err != nil {
+	linesCfg.Errors = stderr
+	defer cli.StopOnSignal(linesCfg)()
+	if err := sign.SignLinesRSA(stdin, stdout, *alg, key, append(headers.SignOptions(), append(passOpts, sign.WithLines(*linesCfg))...)...); err != nil {
 		fmt.Fprintln(stderr, "sign:", err)
+		cli.LinesSummary(stderr, linesCfg)
 		return 1
 	}
-	return 0
+	return cli.LinesSummary(stderr, linesCfg)
 }
 
 // main is the entry point that delegates to run and exits with its status code.
@@ -5,11 +5,15 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
 )
 
 func writeRSAPrivateKeyPEM(t *testing.T, dir string) string {
@@ -44,6 +48,31 @@ func TestRunJwtSignRS256_OK(t *testing.T) {
 	}
 }
 
+func TestRunJwtSignRS256_Alg(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := writeRSAPrivateKeyPEM(t, dir)
+
+	var out, errBuf bytes.Buffer
+	in := strings.NewReader("{\"x\":1}\n")
+	code := run([]string{"--key-file", keyPath, "-alg", "PS512"}, in, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	header, _, _ := strings.Cut(out.String(), ".")
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if !strings.Contains(string(data), `"alg":"PS512"`) {
+		t.Fatalf("unexpected header: %s", data)
+	}
+
+	code = run([]string{"--key-file", keyPath, "-alg", "ES256"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code == 0 {
+		t.Fatalf("expected non-zero exit for non-RSA algorithm")
+	}
+}
+
 func TestRunJwtSignRS256_NoKeyFile(t *testing.T) {
 	var out, errBuf bytes.Buffer
 	code := run([]string{}, &bytes.Buffer{}, &out, &errBuf)
@@ -54,3 +83,48 @@ func TestRunJwtSignRS256_NoKeyFile(t *testing.T) {
 		t.Fatalf("unexpected stderr: %q", errBuf.String())
 	}
 }
+
+func TestRunJwtSignRS256_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	var keys []jwk.Key
+	for _, kid := range []string{"a", "b"} {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		k, err := jwk.FromPrivateKey(priv)
+		if err != nil {
+			t.Fatalf("FromPrivateKey: %v", err)
+		}
+		k.Kid = kid
+		keys = append(keys, *k)
+	}
+	data, err := json.Marshal(jwk.Set{Keys: keys})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	keyPath := filepath.Join(dir, "keys.jwks")
+	if err := os.WriteFile(keyPath, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", keyPath}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected exit code 1 without -kid, got %d", code)
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--key-file", keyPath, "-kid", "b"}, strings.NewReader("{}\n"), &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	header, _, _ := strings.Cut(out.String(), ".")
+	hdr, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatalf("decode header: %v", err)
+	}
+	if string(hdr) != `{"alg":"RS256","kid":"b"}` {
+		t.Fatalf("unexpected header: %s", hdr)
+	}
+}
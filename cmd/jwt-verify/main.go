@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: MIT
+
+// Command jwt-verify checks compact JWS lines from stdin against a public
+// key, JWK Set or HMAC secret, validates exp/nbf/iss/aud, and writes one
+// JSON result per token.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/cli"
+	"github.com/danilkiff/jwt-token-generator/internal/verify"
+)
+
+// run parses CLI flags, loads the verification key, and verifies each
+// input line. It returns a process exit code (0 when every token is
+// valid, 1 when any token fails or on error, 2 on usage errors).
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jwt-verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	keyFile := fs.String("key-file", "", "Path to public key (PEM PKIX or PKCS1, JWK or JWK Set)")
+	secretFile := fs.String("secret-file", "", "Path to HMAC secret (text)")
+	algs := fs.String("alg", "", "Comma-separated accepted algorithms (empty => any matching the key)")
+	iss := fs.String("iss", "", "Required issuer ('iss'), unchecked when empty")
+	var aud []string
+	fs.Func("aud", "Accepted audience ('aud'), repeat for several values", func(v string) error {
+		aud = append(aud, v)
+		return nil
+	})
+	leeway := fs.Duration("leeway", 0, "Clock skew allowed for exp and nbf, e.g. 30s")
+	maxLineBytes := cli.RegisterMaxLineBytesFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintln(stderr, "parse flags:", err)
+		return 2
+	}
+	if (*keyFile == "") == (*secretFile == "") {
+		fmt.Fprintln(stderr, "exactly one of --key-file or --secret-file is required")
+		return 2
+	}
+
+	cfg := verify.Config{Issuer: *iss, Audience: aud, Leeway: *leeway}
+	if *algs != "" {
+		cfg.Algorithms = strings.Split(*algs, ",")
+	}
+
+	var v *verify.Verifier
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "read key file:", err)
+			return 1
+		}
+		v, err = verify.New(data, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "load key:", err)
+			return 1
+		}
+	} else {
+		secret, err := os.ReadFile(*secretFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "read secret file:", err)
+			return 1
+		}
+		v, err = verify.NewHMAC(secret, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "load secret:", err)
+			return 1
+		}
+	}
+
+	bw := bufio.NewWriter(stdout)
+	failed, err := verify.VerifyLines(stdin, bw, v, *maxLineBytes)
+	// The results of the lines read before an input error are written too.
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintln(stderr, "write:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "verify:", err)
+		return 1
+	}
+	if failed > 0 {
+		fmt.Fprintf(stderr, "%d token(s) failed verification\n", failed)
+		return 1
+	}
+	return 0
+}
+
+// main is the entry point that delegates to run and exits with its status code.
+func main() {
+	code := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	os.Exit(code)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeRSAKeys(t *testing.T, dir string) (privPEM []byte, pubPath string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	pubPath = filepath.Join(dir, "rs256.pub")
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}), pubPath
+}
+
+func TestRunJwtVerify_PublicKey(t *testing.T) {
+	dir := t.TempDir()
+	privPEM, pubPath := writeRSAKeys(t, dir)
+	var tokens bytes.Buffer
+	if err := sign.SignLinesRSA(strings.NewReader("{\"a\":1}\n{\"b\":2}\n"), &tokens, "PS256", privPEM); err != nil {
+		t.Fatalf("SignLinesRSA: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	code := run([]string{"--key-file", pubPath, "-alg", "RS256,PS256"}, &tokens, &out, &errBuf)
+	if code != 0 {
+		t.Fatalf("expected 0, got %d (stderr=%q)", code, errBuf.String())
+	}
+	want := "{\"line\":1,\"valid\":true,\"alg\":\"PS256\"}\n{\"line\":2,\"valid\":true,\"alg\":\"PS256\"}\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	errBuf.Reset()
+	token, err := sign.SignRSA(`{}`, "RS512", privPEM)
+	if err != nil {
+		t.Fatalf("SignRSA: %v", err)
+	}
+	code = run([]string{"--key-file", pubPath, "-alg", "RS256,PS256"}, strings.NewReader(token+"\n"), &out, &errBuf)
+	if code != 1 || !strings.Contains(out.String(), `"valid":false`) || !strings.Contains(errBuf.String(), "1 token(s) failed") {
+		t.Fatalf("expected failure for RS512, got %d (stdout=%q, stderr=%q)", code, out.String(), errBuf.String())
+	}
+}
+
+func TestRunJwtVerify_Secret(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(secretPath, []byte(testSecret), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	good, err := sign.SignHS256(`{"iss":"me","aud":["api"]}`, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+	other, err := sign.SignHS256(`{"iss":"you","aud":"api"}`, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	in := strings.NewReader(good + "\n" + other + "\n")
+	code := run([]string{"--secret-file", secretPath, "-iss", "me", "-aud", "api", "-leeway", "30s"}, in, &out, &errBuf)
+	if code != 1 {
+		t.Fatalf("expected 1, got %d (stderr=%q)", code, errBuf.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"valid":true`) || !strings.Contains(lines[1], "issuer mismatch") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestRunJwtVerify_ReadErrorKeepsResults(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secretPath, []byte(testSecret), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	token, err := sign.SignHS256(`{}`, []byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+
+	var out, errBuf bytes.Buffer
+	in := io.MultiReader(strings.NewReader(token+"\n"), iotest.ErrReader(errors.New("broken pipe")))
+	code := run([]string{"--secret-file", secretPath}, in, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "verify: broken pipe") {
+		t.Fatalf("expected read error, got %d (stderr=%q)", code, errBuf.String())
+	}
+	if out.String() != "{\"line\":1,\"valid\":true,\"alg\":\"HS256\"}\n" {
+		t.Fatalf("expected the result of line 1, got %q", out.String())
+	}
+}
+
+func TestRunJwtVerify_Usage(t *testing.T) {
+	var out, errBuf bytes.Buffer
+	code := run([]string{}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 || !strings.Contains(errBuf.String(), "--key-file or --secret-file") {
+		t.Fatalf("expected usage error, got %d (stderr=%q)", code, errBuf.String())
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--key-file", "a", "--secret-file", "b"}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 2 {
+		t.Fatalf("expected 2 for both key sources, got %d", code)
+	}
+
+	errBuf.Reset()
+	code = run([]string{"--key-file", filepath.Join(t.TempDir(), "missing")}, &bytes.Buffer{}, &out, &errBuf)
+	if code != 1 || !strings.Contains(errBuf.String(), "read key file:") {
+		t.Fatalf("expected read error, got %d (stderr=%q)", code, errBuf.String())
+	}
+}
@@ -2,4 +2,7 @@ module github.com/danilkiff/jwt-token-generator
 
 go 1.25.4
 
-require github.com/dvsekhvalnov/jose2go v1.8.0
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.1
+	github.com/dvsekhvalnov/jose2go v1.8.0
+)
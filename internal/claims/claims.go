@@ -7,30 +7,58 @@ package claims
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"iter"
 	"math/rand"
 	"time"
 )
 
-// Claims is a basic set of claims.
+// Audience is the RFC 7519 "aud" claim. A single audience is encoded as
+// a JSON string, several audiences as a JSON array.
+type Audience []string
+
+// MarshalJSON implements json.Marshaler.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Claims is a basic set of claims. Registered claims other than sub and
+// iat are optional and omitted when not configured.
 type Claims struct {
-	Sub string `json:"sub"`
-	Iat int64  `json:"iat"`
-	Rnd string `json:"rnd"`
+	Iss string   `json:"iss,omitempty"`
+	Sub string   `json:"sub"`
+	Aud Audience `json:"aud,omitempty"`
+	Exp *int64   `json:"exp,omitempty"`
+	Nbf *int64   `json:"nbf,omitempty"`
+	Iat int64    `json:"iat"`
+	Jti string   `json:"jti,omitempty"`
+	Rnd string   `json:"rnd"`
 }
 
 // Config defines parameters for claims generation.
 type Config struct {
-	Count        int   // number of claims to generate
-	SubRandomLen int   // random length for sub
-	RndRandomLen int   // random length for rnd
-	UseNowIat    bool  // if true, iat = current time, otherwise FixedIat is used
-	FixedIat     int64 // iat value when UseNowIat=false
-	Seed         int64 // seed for deterministic generation (0 => use current time)
+	Count        int           // number of claims to generate
+	SubRandomLen int           // random length for sub
+	RndRandomLen int           // random length for rnd
+	UseNowIat    bool          // if true, iat = current time, otherwise FixedIat is used
+	FixedIat     int64         // iat value when UseNowIat=false
+	Seed         int64         // seed for deterministic generation (0 => use current time)
+	Issuer       string        // iss value ("" => no iss)
+	Audience     []string      // aud values (empty => no aud)
+	ExpIn        time.Duration // exp offset from iat (0 => no exp)
+	UseNbf       bool          // if true, nbf = iat + NbfSkew
+	NbfSkew      time.Duration // nbf offset from iat, may be negative
+	WithJTI      bool          // if true, every claim set gets a unique jti
 }
 
 var (
 	ErrInvalidCount = errors.New("count must be > 0")
 	ErrInvalidLen   = errors.New("random length must be > 0")
+	ErrInvalidExp   = errors.New("exp offset must be >= 0")
 )
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
@@ -45,39 +73,104 @@ func randomStringDet(r *rand.Rand, n int) string {
 	return string(b)
 }
 
-// GenerateClaims creates a slice of Claims according to the provided config.
-func GenerateClaims(cfg Config) ([]Claims, error) {
-	if cfg.Count <= 0 {
-		return nil, ErrInvalidCount
-	}
-	if cfg.SubRandomLen <= 0 || cfg.RndRandomLen <= 0 {
-		return nil, ErrInvalidLen
-	}
+// randomUUIDDet returns a version 4 UUID string built from the provided
+// RNG, so it is reproducible for a fixed seed.
+func randomUUIDDet(r *rand.Rand) string {
+	var b [16]byte
+	r.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
 
+// newRand returns the RNG for cfg, seeded from cfg.Seed or the current
+// time when no seed is set.
+func newRand(cfg Config) *rand.Rand {
 	seed := cfg.Seed
 	if seed == 0 {
 		seed = time.Now().UnixNano()
 	}
-	r := rand.New(rand.NewSource(seed))
+	return rand.New(rand.NewSource(seed))
+}
 
-	claims := make([]Claims, cfg.Count)
-	var iat int64
+// baseIat returns the iat value shared by all claim sets of cfg.
+func baseIat(cfg Config) int64 {
 	if cfg.UseNowIat {
-		iat = time.Now().Unix()
-	} else {
-		iat = cfg.FixedIat
+		return time.Now().Unix()
 	}
+	return cfg.FixedIat
+}
 
-	for i := 0; i < cfg.Count; i++ {
-		claims[i] = Claims{
-			Sub: randomStringDet(r, cfg.SubRandomLen),
-			Iat: iat,
-			Rnd: randomStringDet(r, cfg.RndRandomLen),
-		}
+// validate checks the parts of cfg used by GenerateClaims and StreamClaims.
+func validate(cfg Config) error {
+	if cfg.Count <= 0 {
+		return ErrInvalidCount
+	}
+	if cfg.SubRandomLen <= 0 || cfg.RndRandomLen <= 0 {
+		return ErrInvalidLen
+	}
+	if cfg.ExpIn < 0 {
+		return ErrInvalidExp
+	}
+	return nil
+}
+
+// GenerateClaims creates a slice of Claims according to the provided config.
+// Prefer StreamClaims for large counts.
+func GenerateClaims(cfg Config) ([]Claims, error) {
+	seq, err := StreamClaims(cfg)
+	if err != nil {
+		return nil, err
+	}
+	claims := make([]Claims, 0, cfg.Count)
+	for c := range seq {
+		claims = append(claims, c)
 	}
 	return claims, nil
 }
 
+// StreamClaims returns an iterator over cfg.Count claim sets. Claim sets
+// are produced one at a time, so memory use does not depend on Count.
+// Each iteration starts over from the configured seed.
+func StreamClaims(cfg Config) (iter.Seq[Claims], error) {
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+
+	var aud Audience
+	if len(cfg.Audience) > 0 {
+		aud = Audience(cfg.Audience)
+	}
+
+	return func(yield func(Claims) bool) {
+		r := newRand(cfg)
+		iat := baseIat(cfg)
+		for i := 0; i < cfg.Count; i++ {
+			c := Claims{
+				Iss: cfg.Issuer,
+				Sub: randomStringDet(r, cfg.SubRandomLen),
+				Aud: aud,
+				Iat: iat,
+				Rnd: randomStringDet(r, cfg.RndRandomLen),
+			}
+			if cfg.ExpIn > 0 {
+				exp := iat + int64(cfg.ExpIn/time.Second)
+				c.Exp = &exp
+			}
+			if cfg.UseNbf {
+				nbf := iat + int64(cfg.NbfSkew/time.Second)
+				c.Nbf = &nbf
+			}
+			if cfg.WithJTI {
+				c.Jti = randomUUIDDet(r)
+			}
+			if !yield(c) {
+				return
+			}
+		}
+	}, nil
+}
+
 // EncodeJSONLines encodes a slice of claims as JSON Lines (JSONL).
 func EncodeJSONLines(cs []Claims) ([]byte, error) {
 	if len(cs) == 0 {
@@ -95,3 +188,15 @@ func EncodeJSONLines(cs []Claims) ([]byte, error) {
 	}
 	return out, nil
 }
+
+// WriteJSONLines encodes claim sets from seq as JSON Lines (JSONL) and
+// writes them to w as they are produced.
+func WriteJSONLines(w io.Writer, seq iter.Seq[Claims]) error {
+	enc := json.NewEncoder(w)
+	for c := range seq {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package claims
 
 import (
+	"bytes"
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateClaimsDeterministic(t *testing.T) {
@@ -28,7 +32,7 @@ func TestGenerateClaimsDeterministic(t *testing.T) {
 		t.Fatalf("expected 3 claims, got %d and %d", len(cs1), len(cs2))
 	}
 	for i := range cs1 {
-		if cs1[i] != cs2[i] {
+		if !reflect.DeepEqual(cs1[i], cs2[i]) {
 			t.Fatalf("claims must be equal for same seed: %#v vs %#v", cs1[i], cs2[i])
 		}
 		if len(cs1[i].Sub) != cfg.SubRandomLen {
@@ -53,6 +57,90 @@ func TestGenerateClaimsErrors(t *testing.T) {
 	if err != ErrInvalidLen {
 		t.Fatalf("expected ErrInvalidLen, got %v", err)
 	}
+
+	_, err = GenerateClaims(Config{Count: 1, SubRandomLen: 8, RndRandomLen: 8, ExpIn: -time.Minute})
+	if err != ErrInvalidExp {
+		t.Fatalf("expected ErrInvalidExp, got %v", err)
+	}
+}
+
+func TestGenerateClaimsRegistered(t *testing.T) {
+	cfg := Config{
+		Count:        3,
+		SubRandomLen: 8,
+		RndRandomLen: 8,
+		FixedIat:     1000,
+		Seed:         7,
+		Issuer:       "https://issuer.example",
+		Audience:     []string{"api"},
+		ExpIn:        15 * time.Minute,
+		UseNbf:       true,
+		NbfSkew:      -30 * time.Second,
+		WithJTI:      true,
+	}
+
+	cs1, err := GenerateClaims(cfg)
+	if err != nil {
+		t.Fatalf("GenerateClaims error: %v", err)
+	}
+	cs2, err := GenerateClaims(cfg)
+	if err != nil {
+		t.Fatalf("GenerateClaims error: %v", err)
+	}
+	if !reflect.DeepEqual(cs1, cs2) {
+		t.Fatalf("claims must be equal for same seed")
+	}
+
+	seen := map[string]bool{}
+	for _, c := range cs1 {
+		if c.Iss != cfg.Issuer {
+			t.Fatalf("unexpected iss: %q", c.Iss)
+		}
+		if c.Exp == nil || *c.Exp != 1900 {
+			t.Fatalf("unexpected exp: %v", c.Exp)
+		}
+		if c.Nbf == nil || *c.Nbf != 970 {
+			t.Fatalf("unexpected nbf: %v", c.Nbf)
+		}
+		if len(c.Jti) != 36 || seen[c.Jti] {
+			t.Fatalf("jti must be a unique UUID, got %q", c.Jti)
+		}
+		seen[c.Jti] = true
+	}
+
+	data, err := json.Marshal(cs1[0])
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"aud":"api"`) {
+		t.Fatalf("single audience must be a string: %s", data)
+	}
+}
+
+func TestGenerateClaimsOptionalOmitted(t *testing.T) {
+	cs, err := GenerateClaims(Config{Count: 1, SubRandomLen: 4, RndRandomLen: 4, Seed: 1})
+	if err != nil {
+		t.Fatalf("GenerateClaims error: %v", err)
+	}
+	data, err := json.Marshal(cs[0])
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	for _, name := range []string{"iss", "aud", "exp", "nbf", "jti"} {
+		if strings.Contains(string(data), `"`+name+`"`) {
+			t.Fatalf("unexpected %s in %s", name, data)
+		}
+	}
+}
+
+func TestAudienceMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Audience{"a", "b"})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if string(data) != `["a","b"]` {
+		t.Fatalf("unexpected audience encoding: %s", data)
+	}
 }
 
 func TestEncodeJSONLines(t *testing.T) {
@@ -78,3 +166,42 @@ func TestEncodeJSONLines(t *testing.T) {
 		t.Fatalf("expected 2 lines, got %d", len(lines))
 	}
 }
+
+func TestStreamClaims(t *testing.T) {
+	cfg := Config{Count: 5, SubRandomLen: 6, RndRandomLen: 6, FixedIat: 1, Seed: 9, WithJTI: true}
+
+	cs, err := GenerateClaims(cfg)
+	if err != nil {
+		t.Fatalf("GenerateClaims error: %v", err)
+	}
+	seq, err := StreamClaims(cfg)
+	if err != nil {
+		t.Fatalf("StreamClaims error: %v", err)
+	}
+	var streamed []Claims
+	for c := range seq {
+		streamed = append(streamed, c)
+		if len(streamed) == 3 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(streamed, cs[:3]) {
+		t.Fatalf("streamed claims differ from generated claims")
+	}
+
+	want, err := EncodeJSONLines(cs)
+	if err != nil {
+		t.Fatalf("EncodeJSONLines error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSONLines(&buf, seq); err != nil {
+		t.Fatalf("WriteJSONLines error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("WriteJSONLines output differs:\n%s\nvs\n%s", buf.Bytes(), want)
+	}
+
+	if _, err := StreamClaims(Config{}); err != ErrInvalidCount {
+		t.Fatalf("expected ErrInvalidCount, got %v", err)
+	}
+}
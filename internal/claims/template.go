@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: MIT
+
+package claims
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template renders custom claim sets from a JSON document with
+// placeholders. Placeholders use text/template syntax and the following
+// functions:
+//
+//	{{randString 16}}    random alphanumeric string of the given length
+//	{{randInt 1 100}}    random integer in the closed range [1, 100]
+//	{{uuid}}             random version 4 UUID
+//	{{now}}              iat of the claim set (epoch seconds)
+//	{{now+15m}}          iat shifted by a time.Duration, e.g. now-30s
+//	{{seq}}              1-based number of the claim set
+//	{{pick "a" "b"}}     one of the given values at random
+//
+// Placeholders are expanded verbatim, so string values must be quoted in
+// the template, e.g. "sub":"{{randString 16}}".
+type Template struct {
+	tmpl *template.Template
+}
+
+var ErrTemplateOutput = errors.New("template output is not a JSON object")
+
+// nowOffsetRe matches the {{now+15m}} / {{now-30s}} shorthand, which is
+// not valid text/template syntax and is rewritten to nowOffset calls.
+var nowOffsetRe = regexp.MustCompile(`\{\{-?\s*now\s*([+-])\s*([0-9][0-9a-zµ.]*)\s*-?\}\}`)
+
+// templateState holds the per-generation state used by template functions.
+type templateState struct {
+	r   *rand.Rand
+	iat int64
+	seq int
+}
+
+func (s *templateState) funcs() template.FuncMap {
+	return template.FuncMap{
+		"randString": func(n int) (string, error) {
+			if n <= 0 {
+				return "", ErrInvalidLen
+			}
+			return randomStringDet(s.r, n), nil
+		},
+		"randInt": func(min, max int) (int, error) {
+			if max < min {
+				return 0, fmt.Errorf("randInt: max %d < min %d", max, min)
+			}
+			return min + s.r.Intn(max-min+1), nil
+		},
+		"uuid": func() string { return randomUUIDDet(s.r) },
+		"now":  func() int64 { return s.iat },
+		"nowOffset": func(offset string) (int64, error) {
+			d, err := time.ParseDuration(offset)
+			if err != nil {
+				return 0, err
+			}
+			return s.iat + int64(d/time.Second), nil
+		},
+		"seq": func() int { return s.seq },
+		"pick": func(values ...any) (any, error) {
+			if len(values) == 0 {
+				return nil, errors.New("pick: no values given")
+			}
+			return values[s.r.Intn(len(values))], nil
+		},
+	}
+}
+
+// ParseTemplate parses a claims template.
+func ParseTemplate(text string) (*Template, error) {
+	text = nowOffsetRe.ReplaceAllString(text, `{{nowOffset "$1$2"}}`)
+	tmpl, err := template.New("claims").
+		Option("missingkey=error").
+		Funcs((&templateState{}).funcs()).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// GenerateFromTemplate renders cfg.Count claim sets from t. Only Count,
+// Seed, UseNowIat and FixedIat of cfg are used. Every rendered claim set
+// is compacted to a single line of JSON. Prefer StreamFromTemplate for
+// large counts.
+func GenerateFromTemplate(t *Template, cfg Config) ([]json.RawMessage, error) {
+	seq, err := StreamFromTemplate(t, cfg)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]json.RawMessage, 0, cfg.Count)
+	for c, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, bytes.Clone(c))
+	}
+	return out, nil
+}
+
+// StreamFromTemplate returns an iterator over cfg.Count claim sets
+// rendered from t, see GenerateFromTemplate. The yielded slice is only
+// valid until the next iteration. Iteration stops after the first error.
+func StreamFromTemplate(t *Template, cfg Config) (iter.Seq2[json.RawMessage, error], error) {
+	if cfg.Count <= 0 {
+		return nil, ErrInvalidCount
+	}
+
+	return func(yield func(json.RawMessage, error) bool) {
+		state := &templateState{r: newRand(cfg), iat: baseIat(cfg)}
+		tmpl, err := t.tmpl.Clone()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		tmpl.Funcs(state.funcs())
+
+		var buf, compact bytes.Buffer
+		for i := 0; i < cfg.Count; i++ {
+			state.seq = i + 1
+			buf.Reset()
+			if err := tmpl.Execute(&buf, nil); err != nil {
+				yield(nil, err)
+				return
+			}
+			compact.Reset()
+			if err := json.Compact(&compact, buf.Bytes()); err != nil {
+				yield(nil, fmt.Errorf("claim set %d: %w: %v", state.seq, ErrTemplateOutput, err))
+				return
+			}
+			if !strings.HasPrefix(compact.String(), "{") {
+				yield(nil, fmt.Errorf("claim set %d: %w", state.seq, ErrTemplateOutput))
+				return
+			}
+			if !yield(compact.Bytes(), nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,132 @@
+package claims
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testTemplate = `{
+  "sub": "{{randString 12}}",
+  "tenant_id": {{randInt 1 100}},
+  "jti": "{{uuid}}",
+  "iat": {{now}},
+  "exp": {{now+15m}},
+  "nbf": {{ now - 30s }},
+  "seq": {{seq}},
+  "roles": ["{{pick "admin" "user"}}"],
+  "profile": {"scope": "read"}
+}`
+
+func TestGenerateFromTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(testTemplate)
+	if err != nil {
+		t.Fatalf("ParseTemplate error: %v", err)
+	}
+	cfg := Config{Count: 3, FixedIat: 1000, Seed: 42}
+
+	out1, err := GenerateFromTemplate(tmpl, cfg)
+	if err != nil {
+		t.Fatalf("GenerateFromTemplate error: %v", err)
+	}
+	out2, err := GenerateFromTemplate(tmpl, cfg)
+	if err != nil {
+		t.Fatalf("GenerateFromTemplate error: %v", err)
+	}
+	if !reflect.DeepEqual(out1, out2) {
+		t.Fatalf("output must be equal for same seed")
+	}
+
+	for i, raw := range out1 {
+		var c struct {
+			Sub      string   `json:"sub"`
+			TenantID int      `json:"tenant_id"`
+			Jti      string   `json:"jti"`
+			Iat      int64    `json:"iat"`
+			Exp      int64    `json:"exp"`
+			Nbf      int64    `json:"nbf"`
+			Seq      int      `json:"seq"`
+			Roles    []string `json:"roles"`
+		}
+		if err := json.Unmarshal(raw, &c); err != nil {
+			t.Fatalf("json.Unmarshal(%s): %v", raw, err)
+		}
+		if len(c.Sub) != 12 || len(c.Jti) != 36 {
+			t.Fatalf("unexpected random values: %s", raw)
+		}
+		if c.TenantID < 1 || c.TenantID > 100 {
+			t.Fatalf("tenant_id out of range: %d", c.TenantID)
+		}
+		if c.Iat != 1000 || c.Exp != 1900 || c.Nbf != 970 {
+			t.Fatalf("unexpected times: %s", raw)
+		}
+		if c.Seq != i+1 {
+			t.Fatalf("expected seq %d, got %d", i+1, c.Seq)
+		}
+		if len(c.Roles) != 1 || (c.Roles[0] != "admin" && c.Roles[0] != "user") {
+			t.Fatalf("unexpected roles: %v", c.Roles)
+		}
+	}
+}
+
+func TestGenerateFromTemplateErrors(t *testing.T) {
+	tmpl, err := ParseTemplate(`{"a":1}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate error: %v", err)
+	}
+	if _, err := GenerateFromTemplate(tmpl, Config{Count: 0}); err != ErrInvalidCount {
+		t.Fatalf("expected ErrInvalidCount, got %v", err)
+	}
+
+	if _, err := ParseTemplate(`{"a":{{unknown}}}`); err == nil {
+		t.Fatalf("expected parse error for unknown function")
+	}
+
+	tmpl, err = ParseTemplate(`{"a":{{randString 4}}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate error: %v", err)
+	}
+	_, err = GenerateFromTemplate(tmpl, Config{Count: 1, Seed: 1})
+	if !errors.Is(err, ErrTemplateOutput) {
+		t.Fatalf("expected ErrTemplateOutput for unquoted string, got %v", err)
+	}
+
+	tmpl, err = ParseTemplate(`{"a":{{randInt 5 1}}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate error: %v", err)
+	}
+	if _, err := GenerateFromTemplate(tmpl, Config{Count: 1, Seed: 1}); err == nil {
+		t.Fatalf("expected error for empty randInt range")
+	}
+}
+
+func TestStreamFromTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`{"n":{{seq}},"v":"{{randString 8}}"}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate error: %v", err)
+	}
+	cfg := Config{Count: 4, Seed: 5}
+
+	want, err := GenerateFromTemplate(tmpl, cfg)
+	if err != nil {
+		t.Fatalf("GenerateFromTemplate error: %v", err)
+	}
+	seq, err := StreamFromTemplate(tmpl, cfg)
+	if err != nil {
+		t.Fatalf("StreamFromTemplate error: %v", err)
+	}
+	i := 0
+	for c, err := range seq {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		if string(c) != string(want[i]) {
+			t.Fatalf("claim set %d differs: %s vs %s", i, c, want[i])
+		}
+		i++
+	}
+	if i != cfg.Count {
+		t.Fatalf("expected %d claim sets, got %d", cfg.Count, i)
+	}
+}
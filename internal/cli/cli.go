@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+
+// Package cli provides flag helpers shared by the command-line tools.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+// HeaderFlags collects protected JOSE header parameters from repeated
+// -header name=value and -header-json flags, and -kid.
+type HeaderFlags struct {
+	Headers     map[string]any
+	OverrideAlg string
+	Kid         string // literal kid, or KidThumbprint
+}
+
+// KidThumbprint is the -kid value requesting the RFC 7638 thumbprint of
+// the signing key as kid.
+const KidThumbprint = "thumbprint"
+
+// RegisterHeaderFlags registers -header, -header-json, -kid and
+// -override-alg on fs and returns the collected values.
+func RegisterHeaderFlags(fs *flag.FlagSet) *HeaderFlags {
+	h := &HeaderFlags{Headers: map[string]any{}}
+	fs.Func("header", "Protected header `name=value` (string value), repeatable", func(v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected name=value, got %q", v)
+		}
+		return h.set(name, value)
+	})
+	fs.Func("header-json", "Protected header parameters as a JSON object, repeatable", func(v string) error {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(v), &obj); err != nil {
+			return fmt.Errorf("expected JSON object: %w", err)
+		}
+		for name, value := range obj {
+			if err := h.set(name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	fs.StringVar(&h.Kid, "kid", "", `Key ID header, also selecting the key from a JWK Set; "`+KidThumbprint+`" uses the RFC 7638 JWK thumbprint of the key`)
+	fs.StringVar(&h.OverrideAlg, "override-alg", "", `Value written to the "alg" header instead of the signing algorithm (negative testing only)`)
+	return h
+}
+
+func (h *HeaderFlags) set(name string, value any) error {
+	if name == "alg" {
+		return sign.ErrAlgHeader
+	}
+	h.Headers[name] = value
+	return nil
+}
+
+// SignOptions returns sign options applying the collected headers.
+func (h *HeaderFlags) SignOptions() []sign.Option {
+	opts := []sign.Option{sign.WithHeaders(h.Headers)}
+	switch h.Kid {
+	case "":
+	case KidThumbprint:
+		opts = append(opts, sign.WithThumbprintKid())
+	default:
+		opts = append(opts, sign.WithHeader("kid", h.Kid))
+	}
+	if h.OverrideAlg != "" {
+		opts = append(opts, sign.OverrideAlg(h.OverrideAlg))
+	}
+	return opts
+}
+
+// PassphraseFlags names the source of the passphrase of an encrypted
+// PKCS8 key file.
+type PassphraseFlags struct {
+	Env  string
+	File string
+}
+
+// RegisterPassphraseFlags registers -key-pass-env and -key-pass-file on fs
+// and returns the collected values.
+func RegisterPassphraseFlags(fs *flag.FlagSet) *PassphraseFlags {
+	p := &PassphraseFlags{}
+	fs.StringVar(&p.Env, "key-pass-env", "", "Environment variable `name` holding the passphrase of an encrypted key file")
+	fs.StringVar(&p.File, "key-pass-file", "", "Path to a file holding the passphrase of an encrypted key file")
+	return p
+}
+
+// Passphrase reads the passphrase, or returns nil when no source is set.
+func (p *PassphraseFlags) Passphrase() ([]byte, error) {
+	return keys.ReadPassphrase(p.Env, p.File)
+}
+
+// SignOptions reads the passphrase and returns the sign option applying it.
+func (p *PassphraseFlags) SignOptions() ([]sign.Option, error) {
+	passphrase, err := p.Passphrase()
+	if err != nil || passphrase == nil {
+		return nil, err
+	}
+	return []sign.Option{sign.WithPassphrase(passphrase)}, nil
+}
+
+// RegisterLinesFlags registers -workers, -unordered, -max-line-bytes and
+// -on-error on fs and returns the collected line processing settings,
+// with Stats set to count the lines. Callers set Errors to receive the
+// lines skipped with -on-error=skip.
+func RegisterLinesFlags(fs *flag.FlagSet) *lines.Config {
+	cfg := &lines.Config{OnError: lines.OnErrorFail, Stats: &lines.Stats{}}
+	fs.IntVar(&cfg.Workers, "workers", runtime.GOMAXPROCS(0), "Number of lines processed concurrently (1 = sequential)")
+	fs.BoolVar(&cfg.Unordered, "unordered", false, "Write results as soon as they are ready instead of in input order")
+	fs.IntVar(&cfg.MaxLineBytes, "max-line-bytes", lines.DefaultMaxLineBytes, maxLineBytesUsage)
+	fs.Func("on-error", "On a failed line: fail (stop), skip (log to stderr) or emit (write a JSON error record in its place) (default fail)", func(v string) error {
+		if !slices.Contains(lines.OnErrorModes, v) {
+			return fmt.Errorf("expected one of %s", strings.Join(lines.OnErrorModes, ", "))
+		}
+		cfg.OnError = v
+		return nil
+	})
+	return cfg
+}
+
+// RegisterPayloadFlags registers -input-format, -raw, -crlf and -base64 on
+// fs, which select how the input becomes payloads, and stores them in cfg.
+func RegisterPayloadFlags(fs *flag.FlagSet, cfg *lines.Config) {
+	fs.Func("input-format", "Input format: lines (one payload per line), json-array (one payload per element), jsonl-envelope (one {\"header\":{...},\"payload\":...} per line) or csv (a header row of claim names, one claim set per row) (default lines)", func(v string) error {
+		if !slices.Contains(lines.Formats, v) {
+			return fmt.Errorf("expected one of %s", strings.Join(lines.Formats, ", "))
+		}
+		cfg.Format = v
+		return nil
+	})
+	fs.BoolVar(&cfg.Raw, "raw", false, "Use the exact bytes between newlines as payload: no whitespace trimming, empty lines included")
+	fs.Func("crlf", "With -raw, strip the \\r of CRLF line endings or keep it in the payload: strip or keep (default strip)", func(v string) error {
+		switch v {
+		case "strip":
+			cfg.KeepCR = false
+		case "keep":
+			cfg.KeepCR = true
+		default:
+			return errors.New("expected strip or keep")
+		}
+		return nil
+	})
+	fs.BoolVar(&cfg.Base64, "base64", false, "Decode each line as base64 (standard or URL-safe) and use the bytes as payload")
+}
+
+// CheckPayloadFlags rejects combinations of the flags of
+// RegisterPayloadFlags that would be ignored. Commands call it after
+// parsing and report an error as a usage error.
+func CheckPayloadFlags(cfg *lines.Config) error {
+	if cfg.KeepCR && !cfg.Raw {
+		return errors.New("-crlf=keep requires -raw")
+	}
+	return nil
+}
+
+// LinesSummary writes the summary of cfg.Stats to w and returns the exit
+// code: 1 when any line failed, 0 otherwise. Commands call it at the end of
+// every run, also after an error stopped processing.
+func LinesSummary(w io.Writer, cfg *lines.Config) int {
+	cfg.Stats.WriteSummary(w)
+	if cfg.Stats.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+const maxLineBytesUsage = "Maximum input line size in bytes"
+
+// RegisterMaxLineBytesFlag registers -max-line-bytes on fs for tools that
+// read lines without the other line processing settings.
+func RegisterMaxLineBytesFlag(fs *flag.FlagSet) *int {
+	return fs.Int("max-line-bytes", lines.DefaultMaxLineBytes, maxLineBytesUsage)
+}
+
+// StopOnSignal sets cfg.Done so that line processing stops, writing and
+// flushing the results of the lines already read, on the first SIGINT or
+// SIGTERM. The default handling is restored right after that signal, so a
+// second one terminates the process. The returned function releases the
+// handler and should be deferred.
+func StopOnSignal(cfg *lines.Config) (stop func()) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cfg.Done = ctx.Done()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return stop
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+)
+
+func newFlagSetWithLines() *flag.FlagSet {
+	fs := newFlagSet()
+	RegisterLinesFlags(fs)
+	return fs
+}
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestHeaderFlags(t *testing.T) {
+	fs := newFlagSet()
+	h := RegisterHeaderFlags(fs)
+	args := []string{
+		"-header", "kid=key-1",
+		"-header", "typ=at+jwt",
+		"-header", "x=a=b",
+		"-header-json", `{"x5c":["MIIB"],"n":1}`,
+		"-override-alg", "none",
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if h.Headers["kid"] != "key-1" || h.Headers["typ"] != "at+jwt" || h.Headers["x"] != "a=b" {
+		t.Fatalf("unexpected headers: %v", h.Headers)
+	}
+	if _, ok := h.Headers["x5c"].([]any); !ok {
+		t.Fatalf("expected array x5c, got %v", h.Headers["x5c"])
+	}
+	if h.OverrideAlg != "none" {
+		t.Fatalf("unexpected override alg: %q", h.OverrideAlg)
+	}
+	if n := len(h.SignOptions()); n != 2 {
+		t.Fatalf("expected 2 sign options, got %d", n)
+	}
+}
+
+func TestHeaderFlagsKid(t *testing.T) {
+	for _, tc := range []struct {
+		kid  string
+		opts int
+	}{{"", 1}, {"key-1", 2}, {KidThumbprint, 2}} {
+		fs := newFlagSet()
+		h := RegisterHeaderFlags(fs)
+		if err := fs.Parse([]string{"-kid", tc.kid}); err != nil {
+			t.Fatalf("Parse error: %v", err)
+		}
+		if n := len(h.SignOptions()); n != tc.opts {
+			t.Fatalf("-kid=%q: expected %d sign options, got %d", tc.kid, tc.opts, n)
+		}
+	}
+}
+
+func TestHeaderFlagsErrors(t *testing.T) {
+	cases := [][]string{
+		{"-header", "novalue"},
+		{"-header", "=v"},
+		{"-header", "alg=none"},
+		{"-header-json", `{"alg":"none"}`},
+		{"-header-json", `[1]`},
+	}
+	for _, args := range cases {
+		fs := newFlagSet()
+		RegisterHeaderFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+
+	fs := newFlagSet()
+	RegisterHeaderFlags(fs)
+	err := fs.Parse([]string{"-header", "alg=none"})
+	if err == nil || !strings.Contains(err.Error(), "-override-alg") {
+		t.Fatalf("expected hint about -override-alg, got %v", err)
+	}
+}
+
+func TestPassphraseFlags(t *testing.T) {
+	fs := newFlagSet()
+	p := RegisterPassphraseFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if opts, err := p.SignOptions(); err != nil || len(opts) != 0 {
+		t.Fatalf("expected no options without passphrase, got %d, %v", len(opts), err)
+	}
+
+	t.Setenv("JWT_TEST_KEY_PASS", "pw")
+	fs = newFlagSet()
+	p = RegisterPassphraseFlags(fs)
+	if err := fs.Parse([]string{"-key-pass-env", "JWT_TEST_KEY_PASS"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if opts, err := p.SignOptions(); err != nil || len(opts) != 1 {
+		t.Fatalf("expected passphrase option, got %d, %v", len(opts), err)
+	}
+	if pass, err := p.Passphrase(); err != nil || string(pass) != "pw" {
+		t.Fatalf("expected passphrase %q, got %q, %v", "pw", pass, err)
+	}
+
+	p.File = "pass.txt"
+	if _, err := p.SignOptions(); err == nil {
+		t.Fatalf("expected error for both passphrase sources")
+	}
+}
+
+func TestLinesFlags(t *testing.T) {
+	fs := newFlagSet()
+	cfg := RegisterLinesFlags(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Workers != runtime.GOMAXPROCS(0) || cfg.Unordered || cfg.MaxLineBytes != lines.DefaultMaxLineBytes {
+		t.Fatalf("unexpected defaults: %+v", *cfg)
+	}
+
+	fs = newFlagSet()
+	cfg = RegisterLinesFlags(fs)
+	if err := fs.Parse([]string{"-workers=3", "-unordered", "-max-line-bytes=1024"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Workers != 3 || !cfg.Unordered || cfg.MaxLineBytes != 1024 {
+		t.Fatalf("unexpected config: %+v", *cfg)
+	}
+
+	if cfg.OnError != lines.OnErrorFail || cfg.Stats == nil {
+		t.Fatalf("unexpected -on-error default: %+v", *cfg)
+	}
+
+	fs = newFlagSet()
+	cfg = RegisterLinesFlags(fs)
+	if err := fs.Parse([]string{"-on-error=emit"}); err != nil || cfg.OnError != lines.OnErrorEmit {
+		t.Fatalf("Parse -on-error=emit: %v, %+v", err, *cfg)
+	}
+	if err := newFlagSetWithLines().Parse([]string{"-on-error=ignore"}); err == nil {
+		t.Fatalf("expected error for unknown -on-error")
+	}
+
+	fs = newFlagSet()
+	maxBytes := RegisterMaxLineBytesFlag(fs)
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if *maxBytes != lines.DefaultMaxLineBytes {
+		t.Fatalf("unexpected -max-line-bytes default: %d", *maxBytes)
+	}
+}
+
+func TestStopOnSignal(t *testing.T) {
+	var cfg lines.Config
+	stop := StopOnSignal(&cfg)
+	defer stop()
+	select {
+	case <-cfg.Done:
+		t.Fatal("Done closed before any signal")
+	default:
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("FindProcess: %v", err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot send interrupt: %v", err)
+	}
+	select {
+	case <-cfg.Done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done not closed after interrupt")
+	}
+}
+
+func TestLinesSummary(t *testing.T) {
+	cfg := &lines.Config{OnError: lines.OnErrorFail, Stats: &lines.Stats{Succeeded: 2}}
+	var w strings.Builder
+	if code := LinesSummary(&w, cfg); code != 0 || w.String() != "lines: 2 processed, 2 succeeded, 0 failed\n" {
+		t.Fatalf("fail mode: %d, %q", code, w.String())
+	}
+
+	w.Reset()
+	cfg.OnError, cfg.Stats.Failed = lines.OnErrorSkip, 1
+	if code := LinesSummary(&w, cfg); code != 1 || w.String() != "lines: 3 processed, 2 succeeded, 1 failed\n" {
+		t.Fatalf("skip mode: %d, %q", code, w.String())
+	}
+}
+
+func TestPayloadFlags(t *testing.T) {
+	fs := newFlagSet()
+	var cfg lines.Config
+	RegisterPayloadFlags(fs, &cfg)
+	if err := fs.Parse([]string{"-raw", "-crlf=keep", "-base64"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !cfg.Raw || !cfg.KeepCR || !cfg.Base64 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if err := CheckPayloadFlags(&cfg); err != nil {
+		t.Fatalf("CheckPayloadFlags: %v", err)
+	}
+	if err := CheckPayloadFlags(&lines.Config{KeepCR: true}); err == nil {
+		t.Fatalf("expected error for -crlf=keep without -raw")
+	}
+
+	fs = newFlagSet()
+	RegisterPayloadFlags(fs, &cfg)
+	if err := fs.Parse([]string{"-crlf=strip"}); err != nil || cfg.KeepCR {
+		t.Fatalf("Parse -crlf=strip: %v, %+v", err, cfg)
+	}
+	if err := fs.Parse([]string{"-crlf=lf"}); err == nil {
+		t.Fatalf("expected error for unknown -crlf")
+	}
+	if err := fs.Parse([]string{"-input-format=csv"}); err != nil || cfg.Format != lines.FormatCSV {
+		t.Fatalf("Parse -input-format=csv: %v, %+v", err, cfg)
+	}
+	if err := fs.Parse([]string{"-input-format=xml"}); err == nil {
+		t.Fatalf("expected error for unknown -input-format")
+	}
+}
@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: MIT
+
+// Package decode splits compact JWS and JWE tokens into their parts for
+// inspection, without verifying or decrypting them.
+package decode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+)
+
+// Token kinds.
+const (
+	KindJWS = "JWS"
+	KindJWE = "JWE"
+)
+
+// Output formats of DecodeLines.
+const (
+	FormatJSONL = "jsonl"
+	FormatText  = "text"
+)
+
+// timeClaims are the NumericDate claims rendered as time.
+var timeClaims = []string{"iat", "nbf", "exp"}
+
+// Token is the decoded form of one compact token. Segment problems are
+// collected in Errors rather than aborting the decoding.
+type Token struct {
+	Line int    `json:"line,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	// Header is the protected header as JSON.
+	Header json.RawMessage `json:"header,omitempty"`
+	// Payload is the JWS payload when it is JSON; PayloadText holds other
+	// UTF-8 payloads.
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	PayloadText string          `json:"payload_text,omitempty"`
+	// Times renders the iat, nbf and exp claims as RFC 3339 UTC.
+	Times map[string]string `json:"times,omitempty"`
+	// SignatureLen is the JWS signature length in bytes.
+	SignatureLen int `json:"signature_len,omitempty"`
+	// JWE segment lengths in bytes.
+	EncryptedKeyLen int `json:"encrypted_key_len,omitempty"`
+	IVLen           int `json:"iv_len,omitempty"`
+	CiphertextLen   int `json:"ciphertext_len,omitempty"`
+	TagLen          int `json:"tag_len,omitempty"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Malformed reports whether any segment could not be decoded.
+func (t *Token) Malformed() bool { return len(t.Errors) > 0 }
+
+func (t *Token) errorf(format string, args ...any) {
+	t.Errors = append(t.Errors, fmt.Sprintf(format, args...))
+}
+
+// segment decodes a base64url segment, recording an error under name.
+func (t *Token) segment(name, s string) ([]byte, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.errorf("%s: %v", name, err)
+		return nil, false
+	}
+	return b, true
+}
+
+// Decode splits a compact JWS (3 segments) or JWE (5 segments).
+func Decode(token string) *Token {
+	t := &Token{}
+	parts := strings.Split(token, ".")
+	var segNames []string
+	switch len(parts) {
+	case 3:
+		t.Kind = KindJWS
+		segNames = []string{"header", "payload", "signature"}
+	case 5:
+		t.Kind = KindJWE
+		segNames = []string{"header", "encrypted key", "iv", "ciphertext", "tag"}
+	default:
+		t.errorf("expected 3 (JWS) or 5 (JWE) segments, got %d", len(parts))
+		return t
+	}
+
+	var obj map[string]any
+	if h, ok := t.segment(segNames[0], parts[0]); ok {
+		if err := json.Unmarshal(h, &obj); err != nil {
+			t.errorf("header: not a JSON object: %v", err)
+		} else {
+			t.Header = compact(h)
+		}
+	}
+
+	if t.Kind == KindJWE {
+		lens := []*int{&t.EncryptedKeyLen, &t.IVLen, &t.CiphertextLen, &t.TagLen}
+		for i, n := range lens {
+			b, ok := t.segment(segNames[i+1], parts[i+1])
+			if !ok {
+				continue
+			}
+			*n = len(b)
+			// The encrypted key is empty with "dir" key management.
+			if len(b) == 0 && i > 0 {
+				t.errorf("%s: empty", segNames[i+1])
+			}
+		}
+		return t
+	}
+
+	if p, ok := t.segment(segNames[1], parts[1]); ok {
+		t.setPayload(p)
+	}
+	if s, ok := t.segment(segNames[2], parts[2]); ok {
+		t.SignatureLen = len(s)
+		// Unsecured JWTs (RFC 7519 section 6) have an empty signature.
+		if len(s) == 0 && obj["alg"] != "none" {
+			t.errorf("signature: empty")
+		}
+	}
+	return t
+}
+
+// setPayload stores a JWS payload and renders its time claims.
+func (t *Token) setPayload(p []byte) {
+	if !json.Valid(p) {
+		if utf8.Valid(p) {
+			t.PayloadText = string(p)
+		} else {
+			t.errorf("payload: %d bytes of binary data", len(p))
+		}
+		return
+	}
+	t.Payload = compact(p)
+
+	var claims map[string]json.RawMessage
+	if json.Unmarshal(p, &claims) != nil {
+		return
+	}
+	for _, name := range timeClaims {
+		raw, ok := claims[name]
+		if !ok {
+			continue
+		}
+		var f float64
+		if err := json.Unmarshal(raw, &f); err != nil || math.IsInf(f, 0) {
+			t.errorf("payload: %s is not a NumericDate: %s", name, raw)
+			continue
+		}
+		if t.Times == nil {
+			t.Times = map[string]string{}
+		}
+		sec, frac := math.Modf(f)
+		t.Times[name] = time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339)
+	}
+}
+
+func compact(b []byte) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return b
+	}
+	return buf.Bytes()
+}
+
+// DecodeLines decodes each non-empty line from r and writes it to w in
+// the given format. Lines longer than maxLineBytes (lines.DefaultMaxLineBytes
+// when zero) are reported as malformed. It returns the number of malformed
+// tokens.
+func DecodeLines(r io.Reader, w io.Writer, format string, now time.Time, maxLineBytes int) (int, error) {
+	if format != FormatJSONL && format != FormatText {
+		return 0, fmt.Errorf("unknown format %q, expected %q or %q", format, FormatJSONL, FormatText)
+	}
+	lr := lines.NewReader(r, maxLineBytes)
+	enc := json.NewEncoder(w)
+	malformed := 0
+	for {
+		raw, err := lr.Next()
+		if err == io.EOF {
+			return malformed, nil
+		}
+		var t *Token
+		var tooLong *lines.LineTooLongError
+		switch {
+		case errors.As(err, &tooLong):
+			t = &Token{}
+			t.errorf("%v", tooLong)
+		case err != nil:
+			return malformed, err
+		default:
+			line := strings.TrimSpace(string(raw))
+			if line == "" {
+				continue
+			}
+			t = Decode(line)
+		}
+		t.Line = lr.Line()
+		if t.Malformed() {
+			malformed++
+		}
+		if format == FormatJSONL {
+			err = enc.Encode(t)
+		} else {
+			err = writeText(w, t, now)
+		}
+		if err != nil {
+			return malformed, err
+		}
+	}
+}
+
+// writeText writes t in an indented human-readable form. Times are shown
+// relative to now.
+func writeText(w io.Writer, t *Token, now time.Time) error {
+	var b strings.Builder
+	kind := t.Kind
+	if kind == "" {
+		kind = "unknown"
+	}
+	fmt.Fprintf(&b, "line %d: %s\n", t.Line, kind)
+	if t.Header != nil {
+		fmt.Fprintf(&b, "header:\n%s\n", indent(t.Header))
+	}
+	if t.Payload != nil {
+		fmt.Fprintf(&b, "payload:\n%s\n", indent(t.Payload))
+	} else if t.PayloadText != "" {
+		fmt.Fprintf(&b, "payload (text):\n  %s\n", t.PayloadText)
+	}
+	for _, name := range timeClaims {
+		if v, ok := t.Times[name]; ok {
+			ts, _ := time.Parse(time.RFC3339, v)
+			fmt.Fprintf(&b, "%s: %s (%s)\n", name, v, relative(ts, now))
+		}
+	}
+	switch t.Kind {
+	case KindJWS:
+		fmt.Fprintf(&b, "signature: %d bytes\n", t.SignatureLen)
+	case KindJWE:
+		fmt.Fprintf(&b, "encrypted key: %d bytes, iv: %d bytes, ciphertext: %d bytes, tag: %d bytes\n",
+			t.EncryptedKeyLen, t.IVLen, t.CiphertextLen, t.TagLen)
+	}
+	for _, e := range t.Errors {
+		fmt.Fprintf(&b, "MALFORMED: %s\n", e)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func indent(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "  ", "  "); err != nil {
+		return "  " + string(raw)
+	}
+	return "  " + buf.String()
+}
+
+// relative describes ts as a duration before or after now.
+func relative(ts, now time.Time) string {
+	d := ts.Sub(now).Round(time.Second)
+	switch {
+	case d > 0:
+		return "in " + d.String()
+	case d < 0:
+		return (-d).String() + " ago"
+	}
+	return "now"
+}
@@ -0,0 +1,152 @@
+package decode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+func TestDecodeJWS(t *testing.T) {
+	token, err := sign.SignHMAC(`{"sub":"a", "iat":1700000000,"exp":1700000900.5}`, "HS512", testSecret, sign.WithHeader("kid", "k1"))
+	if err != nil {
+		t.Fatalf("SignHMAC: %v", err)
+	}
+	d := Decode(token)
+	if d.Malformed() {
+		t.Fatalf("unexpected errors: %v", d.Errors)
+	}
+	if d.Kind != KindJWS || string(d.Header) != `{"alg":"HS512","kid":"k1"}` {
+		t.Fatalf("unexpected header: %s %s", d.Kind, d.Header)
+	}
+	if string(d.Payload) != `{"sub":"a","iat":1700000000,"exp":1700000900.5}` {
+		t.Fatalf("unexpected payload: %s", d.Payload)
+	}
+	if d.SignatureLen != 64 {
+		t.Fatalf("expected 64-byte signature, got %d", d.SignatureLen)
+	}
+	if d.Times["iat"] != "2023-11-14T22:13:20Z" || d.Times["exp"] != "2023-11-14T22:28:20Z" {
+		t.Fatalf("unexpected times: %v", d.Times)
+	}
+
+	text, err := sign.SignHS256("plain text", testSecret)
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+	if d := Decode(text); d.Payload != nil || d.PayloadText != "plain text" || d.Malformed() {
+		t.Fatalf("unexpected text payload decoding: %+v", d)
+	}
+}
+
+func TestDecodeJWE(t *testing.T) {
+	// {"alg":"RSA-OAEP","enc":"A256GCM"} with 4-byte key, 12-byte IV,
+	// 3-byte ciphertext and 16-byte tag.
+	token := "eyJhbGciOiJSU0EtT0FFUCIsImVuYyI6IkEyNTZHQ00ifQ.AQIDBA.AAAAAAAAAAAAAAAA.YWJj.AAAAAAAAAAAAAAAAAAAAAA"
+	d := Decode(token)
+	if d.Malformed() {
+		t.Fatalf("unexpected errors: %v", d.Errors)
+	}
+	if d.Kind != KindJWE || d.EncryptedKeyLen != 4 || d.IVLen != 12 || d.CiphertextLen != 3 || d.TagLen != 16 {
+		t.Fatalf("unexpected JWE decoding: %+v", d)
+	}
+	if d.Payload != nil {
+		t.Fatalf("JWE payload must not be decoded")
+	}
+
+	dir := "eyJhbGciOiJkaXIiLCJlbmMiOiJBMjU2R0NNIn0..AAAAAAAAAAAAAAAA.YWJj."
+	if d := Decode(dir); len(d.Errors) != 1 || !strings.Contains(d.Errors[0], "tag: empty") {
+		t.Fatalf("expected only an empty tag error, got %v", d.Errors)
+	}
+}
+
+func TestDecodeUnsecured(t *testing.T) {
+	// {"alg":"none"} with {"sub":"a"} and no signature.
+	d := Decode("eyJhbGciOiJub25lIn0.eyJzdWIiOiJhIn0.")
+	if d.Malformed() || d.Kind != KindJWS || d.SignatureLen != 0 || string(d.Payload) != `{"sub":"a"}` {
+		t.Fatalf("unexpected decoding of an unsecured JWT: %+v", d)
+	}
+	var b strings.Builder
+	if err := writeText(&b, d, time.Now()); err != nil {
+		t.Fatalf("writeText: %v", err)
+	}
+	if !strings.Contains(b.String(), "signature: 0 bytes\n") || strings.Contains(b.String(), "MALFORMED") {
+		t.Fatalf("unexpected text: %s", b.String())
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	cases := map[string]string{
+		"a.b":                        "expected 3 (JWS) or 5 (JWE) segments, got 2",
+		"!!.e30.AA":                  "header: illegal base64",
+		"WzFd.e30.AA":                "header: not a JSON object",
+		"e30.e30.":                   "signature: empty",
+		"e30.e30.!!":                 "signature: illegal base64",
+		"e30._w.AA":                  "payload: 1 bytes of binary data",
+		`e30.eyJleHAiOiJzb29uIn0.AA`: `payload: exp is not a NumericDate: "soon"`,
+	}
+	for token, want := range cases {
+		d := Decode(token)
+		if !d.Malformed() || !strings.Contains(strings.Join(d.Errors, "; "), want) {
+			t.Fatalf("%q: expected error %q, got %v", token, want, d.Errors)
+		}
+	}
+}
+
+func TestDecodeLines(t *testing.T) {
+	token, err := sign.SignHS256(`{"exp":1700000900}`, testSecret)
+	if err != nil {
+		t.Fatalf("SignHS256: %v", err)
+	}
+	in := token + "\n\nbroken\n"
+	now := time.Unix(1700000000, 0)
+
+	var out bytes.Buffer
+	malformed, err := DecodeLines(strings.NewReader(in), &out, FormatJSONL, now, 0)
+	if err != nil {
+		t.Fatalf("DecodeLines: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if malformed != 1 || len(lines) != 2 {
+		t.Fatalf("expected 2 lines with 1 malformed, got %d, %q", malformed, out.String())
+	}
+	if !strings.HasPrefix(lines[0], `{"line":1,"kind":"JWS","header":{"alg":"HS256"},"payload":{"exp":1700000900},"times":{"exp":"2023-11-14T22:28:20Z"},"signature_len":32}`) {
+		t.Fatalf("unexpected JSONL: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"line":3,"errors":[`) {
+		t.Fatalf("unexpected JSONL: %s", lines[1])
+	}
+
+	out.Reset()
+	if _, err := DecodeLines(strings.NewReader(in), &out, FormatText, now, 0); err != nil {
+		t.Fatalf("DecodeLines text: %v", err)
+	}
+	for _, want := range []string{
+		"line 1: JWS\nheader:\n  {\n    \"alg\": \"HS256\"\n  }\n",
+		"exp: 2023-11-14T22:28:20Z (in 15m0s)\n",
+		"signature: 32 bytes\n",
+		"line 3: unknown\nMALFORMED: expected 3 (JWS) or 5 (JWE) segments, got 1\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("text output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := DecodeLines(strings.NewReader(in), &out, "yaml", now, 0); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+
+	out.Reset()
+	in = strings.Repeat("x", 200) + "\n" + token + "\n"
+	malformed, err = DecodeLines(strings.NewReader(in), &out, FormatJSONL, now, 150)
+	if err != nil {
+		t.Fatalf("DecodeLines with long line: %v", err)
+	}
+	want := `{"line":1,"errors":["200 bytes exceeds the maximum line size of 150 bytes"]}` + "\n" + `{"line":2,"kind":"JWS"`
+	if malformed != 1 || !strings.HasPrefix(out.String(), want) {
+		t.Fatalf("unexpected output for long line: %d, %s", malformed, out.String())
+	}
+}
@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: MIT
+
+package encrypt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+var (
+	ErrMalformed   = errors.New("malformed JWE")
+	ErrUnsupported = errors.New("unsupported algorithm")
+	ErrNoKey       = errors.New("no key for token")
+	ErrKeyUnwrap   = errors.New("cannot decrypt the content encryption key (wrong key?)")
+	ErrTagMismatch = errors.New("content decryption failed (authentication tag mismatch)")
+	ErrDecompress  = errors.New("cannot decompress plaintext")
+)
+
+// Decrypted is a decrypted JWE.
+type Decrypted struct {
+	// Header is the protected header as JSON.
+	Header    json.RawMessage
+	Plaintext []byte
+}
+
+// Decrypter decrypts compact JWE with a fixed set of private keys.
+type Decrypter struct {
+	// set holds the JWK metadata used to select a key, keys the parsed
+	// private key of each of its members.
+	set  jwk.Set
+	keys map[*jwk.Key]any
+}
+
+// newDecrypter returns a Decrypter for the single key.
+func newDecrypter(key any) (*Decrypter, error) {
+	meta, err := jwk.FromPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decrypter{set: jwk.Set{Keys: []jwk.Key{*meta}}}
+	d.keys = map[*jwk.Key]any{&d.set.Keys[0]: key}
+	return d, nil
+}
+
+// NewDecrypter returns a Decrypter for keyData, which is a PEM private key
+// (encrypted PKCS8 is decrypted with passphrase), a private JWK or a JWK
+// Set. Keys of a JWK Set without private members are ignored; the others
+// are selected by the token's "kid" header, or by key type when the token
+// has none.
+func NewDecrypter(keyData, passphrase []byte) (*Decrypter, error) {
+	if !jwk.IsJSON(keyData) {
+		key, err := keys.ParsePrivateKey(keyData, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return newDecrypter(key)
+	}
+
+	set, err := jwk.ParseSet(keyData)
+	if err != nil {
+		return nil, err
+	}
+	var private []jwk.Key
+	var parsed []any
+	for i, k := range set.Keys {
+		key, err := k.PrivateKey()
+		if errors.Is(err, jwk.ErrNotPrivate) && len(set.Keys) > 1 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWK %d (kid %q): %w", i, k.Kid, err)
+		}
+		private = append(private, k)
+		parsed = append(parsed, key)
+	}
+	if len(private) == 0 {
+		return nil, fmt.Errorf("%w: JWK Set has no private keys", ErrNoKey)
+	}
+	d := &Decrypter{set: jwk.Set{Keys: private}, keys: make(map[*jwk.Key]any)}
+	for i := range d.set.Keys {
+		d.keys[&d.set.Keys[i]] = parsed[i]
+	}
+	return d, nil
+}
+
+// NewSecretDecrypter returns a Decrypter for a raw symmetric key: the key
+// for A128KW, A128GCMKW and their siblings, the content encryption key
+// for dir, or the password for PBES2.
+func NewSecretDecrypter(secret []byte) (*Decrypter, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+	return newDecrypter(append([]byte(nil), secret...))
+}
+
+// Decrypt decrypts a compact JWE. The steps jose.Decode performs are run
+// one by one so that errors tell a wrong key (ErrKeyUnwrap) from corrupt
+// content (ErrTagMismatch). With RSA1_5 a wrong key shows up as
+// ErrTagMismatch, since the key unwrap hides its failure by design.
+func (d *Decrypter) Decrypt(token string) (*Decrypted, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 segments, got %d", ErrMalformed, len(parts))
+	}
+	var raw [5][]byte
+	names := [5]string{"header", "encrypted key", "iv", "ciphertext", "tag"}
+	for i, p := range parts {
+		b, err := base64.RawURLEncoding.DecodeString(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, names[i], err)
+		}
+		raw[i] = b
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+		Enc string `json:"enc"`
+		Zip string `json:"zip"`
+		Kid string `json:"kid"`
+	}
+	var header map[string]any
+	if err := json.Unmarshal(raw[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	if err := json.Unmarshal(raw[0], &hdr); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+
+	a, ok := LookupKeyAlgorithm(hdr.Alg)
+	jwa := jwaAlgorithms[hdr.Alg]
+	if !ok || jwa == nil {
+		return nil, fmt.Errorf("%w: alg %q", ErrUnsupported, hdr.Alg)
+	}
+	jwe := jweAlgorithms[hdr.Enc]
+	if jwe == nil {
+		return nil, fmt.Errorf("%w: enc %q", ErrUnsupported, hdr.Enc)
+	}
+	var jwc jose.JwcAlgorithm
+	if hdr.Zip != "" {
+		if jwc = jwcAlgorithms[hdr.Zip]; jwc == nil {
+			return nil, fmt.Errorf("%w: zip %q", ErrUnsupported, hdr.Zip)
+		}
+	}
+
+	candidates := d.set.Select(a.KeyType, "", a.Name, "enc", hdr.Kid)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: alg %s, kid %q", ErrNoKey, hdr.Alg, hdr.Kid)
+	}
+	var cek []byte
+	var err error
+	for _, k := range candidates {
+		if cek, err = jwa.Unwrap(raw[1], d.keys[k], jwe.KeySizeBits(), header); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyUnwrap, err)
+	}
+
+	plaintext, err := jwe.Decrypt([]byte(parts[0]), cek, raw[2], raw[3], raw[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTagMismatch, err)
+	}
+	if jwc != nil {
+		if plaintext, err = jwc.Decompress(plaintext); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecompress, err)
+		}
+	}
+	return &Decrypted{Header: raw[0], Plaintext: plaintext}, nil
+}
+
+// DecryptLines decrypts each non-empty line from r and writes the
+// plaintext to w, one per line. With withHeader each line is instead a
+// JSON object {"header":...,"payload":...}, where payload is embedded as
+// JSON when it is valid JSON and as a string otherwise. cfg selects the
+// number of workers, the output order and what happens to lines that
+// fail, see lines.Config.
+func DecryptLines(r io.Reader, w io.Writer, d *Decrypter, withHeader bool, cfg lines.Config) error {
+	return lines.Process(r, w, cfg, func(_ int, line []byte) (string, error) {
+		dec, err := d.Decrypt(string(line))
+		if err != nil {
+			return "", err
+		}
+		out := dec.Plaintext
+		if withHeader {
+			if out, err = withHeaderJSON(dec); err != nil {
+				return "", err
+			}
+		}
+		return string(out), nil
+	})
+}
+
+func withHeaderJSON(dec *Decrypted) ([]byte, error) {
+	payload := json.RawMessage(dec.Plaintext)
+	if !json.Valid(dec.Plaintext) {
+		s, err := json.Marshal(string(dec.Plaintext))
+		if err != nil {
+			return nil, err
+		}
+		payload = s
+	}
+	return json.Marshal(struct {
+		Header  json.RawMessage `json:"header"`
+		Payload json.RawMessage `json:"payload"`
+	}{dec.Header, payload})
+}
@@ -0,0 +1,206 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+func rsaPrivatePEM(t *testing.T, priv *rsa.PrivateKey) []byte {
+	t.Helper()
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+}
+
+func TestDecryptRoundTrip(t *testing.T) {
+	pubPEM, priv := genRSAPublicPEM(t)
+	d, err := NewDecrypter(rsaPrivatePEM(t, priv), nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+
+	token, err := EncryptRSAOAEP_A256GCM(`{"x":42}`, pubPEM)
+	if err != nil {
+		t.Fatalf("EncryptRSAOAEP_A256GCM: %v", err)
+	}
+	dec, err := d.Decrypt(token)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(dec.Plaintext) != `{"x":42}` || string(dec.Header) != `{"alg":"RSA-OAEP","enc":"A256GCM"}` {
+		t.Fatalf("unexpected result: %s %s", dec.Header, dec.Plaintext)
+	}
+
+	for _, enc := range []string{jose.A128CBC_HS256, jose.A256CBC_HS512, jose.A128GCM} {
+		token, err := jose.Encrypt("payload", jose.RSA_OAEP_256, enc, &priv.PublicKey, jose.Zip(jose.DEF))
+		if err != nil {
+			t.Fatalf("jose.Encrypt %s: %v", enc, err)
+		}
+		dec, err := d.Decrypt(token)
+		if err != nil || string(dec.Plaintext) != "payload" {
+			t.Fatalf("%s: Decrypt: %q, %v", enc, dec, err)
+		}
+	}
+}
+
+func TestDecryptFailureReasons(t *testing.T) {
+	pubPEM, priv := genRSAPublicPEM(t)
+	_, other := genRSAPublicPEM(t)
+	d, err := NewDecrypter(rsaPrivatePEM(t, priv), nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	wrong, err := NewDecrypter(rsaPrivatePEM(t, other), nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+
+	token, err := EncryptRSAOAEP_A256GCM("p", pubPEM)
+	if err != nil {
+		t.Fatalf("EncryptRSAOAEP_A256GCM: %v", err)
+	}
+	if _, err := wrong.Decrypt(token); !errors.Is(err, ErrKeyUnwrap) {
+		t.Fatalf("expected ErrKeyUnwrap for wrong key, got %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	parts[4] = "AAAAAAAAAAAAAAAAAAAAAA"
+	if _, err := d.Decrypt(strings.Join(parts, ".")); !errors.Is(err, ErrTagMismatch) {
+		t.Fatalf("expected ErrTagMismatch for modified tag, got %v", err)
+	}
+
+	cbc, err := jose.Encrypt("p", jose.RSA_OAEP, jose.A256CBC_HS512, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("jose.Encrypt: %v", err)
+	}
+	parts = strings.Split(cbc, ".")
+	parts[3] = "AAAAAAAAAAAAAAAAAAAAAA"
+	if _, err := d.Decrypt(strings.Join(parts, ".")); !errors.Is(err, ErrTagMismatch) {
+		t.Fatalf("expected ErrTagMismatch for modified ciphertext, got %v", err)
+	}
+
+	cases := map[string]error{
+		"a.b.c":                    ErrMalformed,
+		"!!.a.b.c.d":               ErrMalformed,
+		"e30.AA.AA.AA.AA":          ErrUnsupported,
+		"eyJhbGciOjF9.AA.AA.AA.AA": ErrMalformed,
+		// {"alg":"A128KW","enc":"A128GCM"} has no "oct" key here.
+		"eyJhbGciOiJBMTI4S1ciLCJlbmMiOiJBMTI4R0NNIn0.AA.AA.AA.AA": ErrNoKey,
+	}
+	for token, want := range cases {
+		if _, err := d.Decrypt(token); !errors.Is(err, want) {
+			t.Fatalf("%q: expected %v, got %v", token, want, err)
+		}
+	}
+}
+
+func TestDecryptJWKSet(t *testing.T) {
+	_, priv := genRSAPublicPEM(t)
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	secret := bytes.Repeat([]byte{7}, 32)
+
+	var set jwk.Set
+	for kid, key := range map[string]any{"rsa": priv, "ec": ec, "oct": secret} {
+		k, err := jwk.FromPrivateKey(key)
+		if err != nil {
+			t.Fatalf("FromPrivateKey: %v", err)
+		}
+		k.Kid = kid
+		set.Keys = append(set.Keys, *k)
+	}
+	pub, err := jwk.FromPublicKey(ec)
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	set.Keys = append(set.Keys, *pub)
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	d, err := NewDecrypter(data, nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+
+	tokens := map[string]string{}
+	if tokens["rsa"], err = jose.Encrypt("rsa", jose.RSA_OAEP, jose.A256GCM, &priv.PublicKey, jose.Header("kid", "rsa")); err != nil {
+		t.Fatalf("jose.Encrypt: %v", err)
+	}
+	if tokens["ec"], err = jose.Encrypt("ec", jose.ECDH_ES_A128KW, jose.A128GCM, &ec.PublicKey); err != nil {
+		t.Fatalf("jose.Encrypt: %v", err)
+	}
+	if tokens["oct"], err = jose.Encrypt("oct", jose.DIR, jose.A256GCM, secret); err != nil {
+		t.Fatalf("jose.Encrypt: %v", err)
+	}
+	for want, token := range tokens {
+		dec, err := d.Decrypt(token)
+		if err != nil || string(dec.Plaintext) != want {
+			t.Fatalf("%s: Decrypt: %v", want, err)
+		}
+	}
+
+	if _, err := NewDecrypter(mustJSON(t, jwk.Set{Keys: []jwk.Key{*pub, *pub}}), nil); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for public-only set, got %v", err)
+	}
+	if _, err := NewDecrypter(mustJSON(t, pub), nil); !errors.Is(err, jwk.ErrNotPrivate) {
+		t.Fatalf("expected ErrNotPrivate for public JWK, got %v", err)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return data
+}
+
+func TestDecryptLines(t *testing.T) {
+	pubPEM, priv := genRSAPublicPEM(t)
+	d, err := NewDecrypter(rsaPrivatePEM(t, priv), nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	var tokens bytes.Buffer
+	if err := EncryptLinesRSAOAEP_A256GCM(strings.NewReader("{\"a\":1}\n\nplain\n"), &tokens, pubPEM); err != nil {
+		t.Fatalf("EncryptLines: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := DecryptLines(bytes.NewReader(tokens.Bytes()), &out, d, false, lines.Config{}); err != nil {
+		t.Fatalf("DecryptLines: %v", err)
+	}
+	if out.String() != "{\"a\":1}\nplain\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	if err := DecryptLines(bytes.NewReader(tokens.Bytes()), &out, d, true, lines.Config{Workers: 2}); err != nil {
+		t.Fatalf("DecryptLines with header: %v", err)
+	}
+	want := `{"header":{"alg":"RSA-OAEP","enc":"A256GCM"},"payload":{"a":1}}` + "\n" +
+		`{"header":{"alg":"RSA-OAEP","enc":"A256GCM"},"payload":"plain"}` + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	err = DecryptLines(strings.NewReader(tokens.String()+"\nbroken\n"), &out, d, false, lines.Config{})
+	if !errors.Is(err, ErrMalformed) || !strings.HasPrefix(err.Error(), "line 4: ") {
+		t.Fatalf("expected malformed error on line 4, got %v", err)
+	}
+}
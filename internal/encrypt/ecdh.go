@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: MIT
+
+package encrypt
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/dvsekhvalnov/jose2go/arrays"
+	"github.com/dvsekhvalnov/jose2go/base64url"
+	"github.com/dvsekhvalnov/jose2go/kdf"
+)
+
+// ecdhAlgorithm implements jose.JwaAlgorithm for ECDH-ES (RFC 7518 section
+// 4.6): direct key agreement when kw is nil, otherwise key agreement on a
+// key for kw, which wraps the content encryption key. Unlike jose2go's
+// implementation it keeps the padded coordinates of the ephemeral key;
+// jose2go picks the curve from the length of the unpadded x coordinate
+// and panics when it starts with a zero byte.
+type ecdhAlgorithm struct {
+	name string
+	kw   *aesKWAlgorithm
+}
+
+func (a *ecdhAlgorithm) Name() string { return a.name }
+
+func (a *ecdhAlgorithm) WrapNewKey(cekSizeBits int, key interface{}, header map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s WrapNewKey: expected *ecdsa.PublicKey, got %T", a.name, key)
+	}
+	recipient, err := pub.ECDH()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s WrapNewKey: %w", a.name, err)
+	}
+	ephemeral, err := recipient.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.wrapWith(ephemeral, recipient, pub.Curve.Params().Name, cekSizeBits, header)
+}
+
+// wrapWith is WrapNewKey with the ephemeral key given, which makes the
+// result reproducible for tests. crv is the JWK name of the curve.
+func (a *ecdhAlgorithm) wrapWith(ephemeral *ecdh.PrivateKey, recipient *ecdh.PublicKey, crv string, cekSizeBits int, header map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	// An uncompressed point is 0x04 followed by the padded X and Y.
+	point := ephemeral.PublicKey().Bytes()[1:]
+	size := len(point) / 2
+	header["epk"] = map[string]string{
+		"kty": "EC",
+		"crv": crv,
+		"x":   base64url.Encode(point[:size]),
+		"y":   base64url.Encode(point[size:]),
+	}
+	z, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.kw == nil {
+		return a.deriveKey(z, cekSizeBits, header), nil, nil
+	}
+	return a.kw.WrapNewKey(cekSizeBits, a.deriveKey(z, a.kw.bits, header), header)
+}
+
+func (a *ecdhAlgorithm) Unwrap(encryptedCek []byte, key interface{}, cekSizeBits int, header map[string]interface{}) ([]byte, error) {
+	priv, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s Unwrap: expected *ecdsa.PrivateKey, got %T", a.name, key)
+	}
+	recipient, err := priv.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("%s Unwrap: %w", a.name, err)
+	}
+	ephemeral, err := parseEPK(header["epk"], priv.Curve.Params().Name, recipient.Curve())
+	if err != nil {
+		return nil, fmt.Errorf("%s Unwrap: %w", a.name, err)
+	}
+	z, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	if a.kw == nil {
+		return a.deriveKey(z, cekSizeBits, header), nil
+	}
+	return a.kw.Unwrap(encryptedCek, a.deriveKey(z, a.kw.bits, header), cekSizeBits, header)
+}
+
+// deriveKey runs the Concat KDF of RFC 7518 section 4.6.2 over the shared
+// secret z. The algorithm ID is "enc" for direct key agreement and "alg"
+// otherwise; "apu" and "apv" are used when the header has them.
+func (a *ecdhAlgorithm) deriveKey(z []byte, keyBits int, header map[string]interface{}) []byte {
+	id := a.name
+	if a.kw == nil {
+		id, _ = header["enc"].(string)
+	}
+	apu := headerBytes(header, "apu")
+	apv := headerBytes(header, "apv")
+	return kdf.DeriveConcatKDF(keyBits, z, withLength([]byte(id)), withLength(apu), withLength(apv),
+		arrays.UInt32ToBytes(uint32(keyBits)), nil, sha256.New())
+}
+
+// parseEPK returns the "epk" header value as a public key on curve, whose
+// JWK name is crv.
+func parseEPK(value any, crv string, curve ecdh.Curve) (*ecdh.PublicKey, error) {
+	epk, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New(`missing or invalid "epk" header`)
+	}
+	if epk["kty"] != "EC" || epk["crv"] != crv {
+		return nil, fmt.Errorf(`"epk" header is not a %s key`, crv)
+	}
+	x, y := headerBytes(epk, "x"), headerBytes(epk, "y")
+	if len(x) == 0 || len(x) != len(y) {
+		return nil, errors.New(`invalid "epk" coordinates`)
+	}
+	pub, err := curve.NewPublicKey(append(append([]byte{4}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf(`invalid "epk" header: %w`, err)
+	}
+	return pub, nil
+}
+
+// headerBytes returns the base64url-decoded string member name of header,
+// or nil.
+func headerBytes(header map[string]interface{}, name string) []byte {
+	s, _ := header[name].(string)
+	b, err := base64url.Decode(s)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// withLength prefixes b with its length as a 32-bit big-endian integer.
+func withLength(b []byte) []byte {
+	return append(arrays.UInt32ToBytes(uint32(len(b))), b...)
+}
@@ -0,0 +1,119 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+// rawECKey returns the private key d (base64url) on curve.
+func rawECKey(t *testing.T, curve elliptic.Curve, d string) *ecdsa.PrivateKey {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(d)
+	if err != nil {
+		t.Fatalf("decode d: %v", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(curve, b)
+	if err != nil {
+		t.Fatalf("ParseRawPrivateKey: %v", err)
+	}
+	return key
+}
+
+// roundTripHeader returns header as a recipient sees it after JSON
+// encoding.
+func roundTripHeader(t *testing.T, header map[string]any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	return out
+}
+
+// TestECDHKnownAnswer derives the key of the ECDH-ES example in RFC 7518
+// Appendix C from Alice's ephemeral key and Bob's key, on both sides.
+func TestECDHKnownAnswer(t *testing.T) {
+	alice := rawECKey(t, elliptic.P256(), "0_NxaRPUMQoAJt50Gz8YiTr8gRTwyEaCumd-MToTmIo")
+	bob := rawECKey(t, elliptic.P256(), "VEmDZpDXXK8p8N0Cndsxs924q6nS1RXFASRl6BfUqdw")
+	ephemeral, err := alice.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	recipient, err := bob.PublicKey.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+
+	a := jwaAlgorithms[jose.ECDH_ES].(*ecdhAlgorithm)
+	header := map[string]any{"enc": jose.A128GCM, "apu": "QWxpY2U", "apv": "Qm9i"}
+	cek, encryptedCek, err := a.wrapWith(ephemeral, recipient, "P-256", 128, header)
+	if err != nil {
+		t.Fatalf("wrapWith: %v", err)
+	}
+	const want = "VqqN6vgjbSBcIijNcacQGg"
+	if got := base64.RawURLEncoding.EncodeToString(cek); got != want || encryptedCek != nil {
+		t.Fatalf("expected derived key %s, got %s (encrypted key %x)", want, got, encryptedCek)
+	}
+	epk := header["epk"].(map[string]string)
+	if epk["x"] != "gI0GAILBdu7T53akrFmMyGcsF3n5dO7MmwNBHKW5SV0" || epk["y"] != "SLW_xSffzlPWrHEVI30DHM_4egVwt3NQqeUD7nMFpps" {
+		t.Fatalf("unexpected epk: %v", epk)
+	}
+
+	got, err := a.Unwrap(nil, bob, 128, roundTripHeader(t, header))
+	if err != nil || base64.RawURLEncoding.EncodeToString(got) != want {
+		t.Fatalf("Unwrap: %x, %v", got, err)
+	}
+}
+
+// TestECDHLeadingZeroCoordinate wraps a key with an ephemeral P-384 key
+// whose x coordinate starts with a zero byte, on which jose2go's own
+// ECDH-ES panics, and unwraps it again.
+func TestECDHLeadingZeroCoordinate(t *testing.T) {
+	// 197 is the smallest P-384 scalar with such a public key.
+	d := make([]byte, 48)
+	d[47] = 197
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P384(), d)
+	if err != nil {
+		t.Fatalf("ParseRawPrivateKey: %v", err)
+	}
+	ephemeral, err := key.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	recipient, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+
+	for _, alg := range []string{jose.ECDH_ES, jose.ECDH_ES_A256KW} {
+		a := jwaAlgorithms[alg].(*ecdhAlgorithm)
+		header := map[string]any{"enc": jose.A128GCM}
+		cek, encryptedCek, err := a.wrapWith(ephemeral, recipient, "P-384", 128, header)
+		if err != nil {
+			t.Fatalf("%s: wrapWith: %v", alg, err)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(header["epk"].(map[string]string)["x"])
+		if err != nil || len(x) != 48 || x[0] != 0 {
+			t.Fatalf("%s: expected a 48-byte epk x with a leading zero byte, got %x, %v", alg, x, err)
+		}
+		got, err := a.Unwrap(encryptedCek, priv, 128, roundTripHeader(t, header))
+		if err != nil || !bytes.Equal(got, cek) {
+			t.Fatalf("%s: Unwrap: %x, %v", alg, got, err)
+		}
+	}
+}
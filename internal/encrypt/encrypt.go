@@ -5,57 +5,64 @@
 package encrypt
 
 import (
-	"bufio"
+	"crypto/rsa"
 	"fmt"
 	"io"
 	"strings"
 
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
 	jose "github.com/dvsekhvalnov/jose2go"
-	Rsa "github.com/dvsekhvalnov/jose2go/keys/rsa"
 )
 
+// parseRSAPublicKey parses an RSA public key from PEM-encoded PKIX or
+// PKCS1 bytes.
+func parseRSAPublicKey(pubPEM []byte) (*rsa.PublicKey, error) {
+	if len(pubPEM) == 0 {
+		return nil, fmt.Errorf("public key must not be empty")
+	}
+	key, err := keys.ParsePublicKey(pubPEM)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected RSA public key, got %T", key)
+	}
+	return pub, nil
+}
+
 // EncryptRSAOAEP_A256GCM encrypts a payload string into a compact JWE
 // using RSA-OAEP and A256GCM.
 func EncryptRSAOAEP_A256GCM(payload string, pubPEM []byte) (string, error) {
-	if len(pubPEM) == 0 {
-		return "", fmt.Errorf("public key must not be empty")
-	}
-	pub, err := Rsa.ReadPublic(pubPEM)
+	pub, err := parseRSAPublicKey(pubPEM)
 	if err != nil {
 		return "", err
 	}
-	token, err := jose.Encrypt(payload, jose.RSA_OAEP, jose.A256GCM, pub)
+	e, err := NewEncrypter(jose.RSA_OAEP, pub, WithEnc(jose.A256GCM))
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+	return e.Encrypt([]byte(payload))
 }
 
 // EncryptLinesRSAOAEP_A256GCM encrypts each non-empty line from r
-// and writes resulting JWE tokens to w.
-func EncryptLinesRSAOAEP_A256GCM(r io.Reader, w io.Writer, pubPEM []byte) error {
-	if len(pubPEM) == 0 {
-		return fmt.Errorf("public key must not be empty")
+// and writes resulting JWE tokens to w. opts may set headers and, with
+// WithLines, how lines are processed; the content encryption is always
+// A256GCM.
+func EncryptLinesRSAOAEP_A256GCM(r io.Reader, w io.Writer, pubPEM []byte, opts ...Option) error {
+	pub, err := parseRSAPublicKey(pubPEM)
+	if err != nil {
+		return err
 	}
-	pub, err := Rsa.ReadPublic(pubPEM)
+	e, err := NewEncrypter(jose.RSA_OAEP, pub, append(opts, WithEnc(jose.A256GCM))...)
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		token, err := jose.Encrypt(line, jose.RSA_OAEP, jose.A256GCM, pub)
-		if err != nil {
-			return err
-		}
-		if _, err := io.WriteString(w, token+"\n"); err != nil {
-			return err
-		}
+	var o encrypterOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
-	return scanner.Err()
+	return EncryptLines(r, w, e, o.lines)
 }
 
 // IsCompactJWE returns true if the string looks like a compact JWE
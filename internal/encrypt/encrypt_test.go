@@ -1,14 +1,19 @@
 package encrypt
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
 	jose "github.com/dvsekhvalnov/jose2go"
 )
 
@@ -87,6 +92,24 @@ func TestEncryptRSAOAEP_A256GCMErrors(t *testing.T) {
 	}
 }
 
+func TestEncryptRSAOAEP_A256GCMKeyFormats(t *testing.T) {
+	_, priv := genRSAPublicPEM(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&priv.PublicKey)})
+	token, err := EncryptRSAOAEP_A256GCM("p", pkcs1)
+	if err != nil {
+		t.Fatalf("EncryptRSAOAEP_A256GCM PKCS1 error: %v", err)
+	}
+	if payload, _, err := jose.Decode(token, priv); err != nil || payload != "p" {
+		t.Fatalf("jose.Decode: %q %v", payload, err)
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	_, err = EncryptRSAOAEP_A256GCM("p", privPEM)
+	if err == nil || !strings.Contains(err.Error(), `"RSA PRIVATE KEY"`) {
+		t.Fatalf("expected error naming the private key block, got %v", err)
+	}
+}
+
 func TestEncryptLinesRSAOAEP_A256GCMErrors(t *testing.T) {
 	err := EncryptLinesRSAOAEP_A256GCM(strings.NewReader("p"), &bytes.Buffer{}, nil)
 	if err == nil {
@@ -106,3 +129,63 @@ func TestIsCompactJWE(t *testing.T) {
 		t.Fatalf("expected false for 3 parts")
 	}
 }
+
+// benchLines is the number of input lines per benchmark iteration.
+const benchLines = 10000
+
+// encryptLinesUnbuffered is the output path EncryptLines used before it
+// went through lines.Process: one write per token, straight to w.
+func encryptLinesUnbuffered(r io.Reader, w io.Writer, e *Encrypter) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		tok, err := e.Encrypt([]byte(line))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, tok+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// BenchmarkEncryptLinesDir compares the unbuffered output path with
+// EncryptLines writing to a file, as the commands do with stdout. Direct
+// encryption with A128GCM keeps the per-token cost low enough for the
+// output to matter.
+func BenchmarkEncryptLinesDir(b *testing.B) {
+	e, err := NewEncrypter(jose.DIR, bytes.Repeat([]byte{7}, 16), WithEnc(jose.A128GCM))
+	if err != nil {
+		b.Fatalf("NewEncrypter: %v", err)
+	}
+	var input bytes.Buffer
+	for i := range benchLines {
+		fmt.Fprintf(&input, "{\"sub\":\"user-%d\",\"iat\":1700000000}\n", i)
+	}
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	defer out.Close()
+
+	b.Run("unbuffered", func(b *testing.B) {
+		for b.Loop() {
+			if err := encryptLinesUnbuffered(bytes.NewReader(input.Bytes()), out, e); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N*benchLines)/b.Elapsed().Seconds(), "tokens/s")
+	})
+	b.Run("buffered", func(b *testing.B) {
+		for b.Loop() {
+			if err := EncryptLines(bytes.NewReader(input.Bytes()), out, e, lines.Config{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N*benchLines)/b.Elapsed().Seconds(), "tokens/s")
+	})
+}
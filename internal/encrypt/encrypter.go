@@ -0,0 +1,410 @@
+// SPDX-License-Identifier: MIT
+
+package encrypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	jose "github.com/dvsekhvalnov/jose2go"
+	"github.com/dvsekhvalnov/jose2go/compact"
+)
+
+// DefaultEnc is the content encryption algorithm used by Encrypter unless
+// WithEnc selects another.
+const DefaultEnc = jose.A256GCM
+
+// contentAlgorithms are the content encryption algorithms of RFC 7518
+// section 5.
+var contentAlgorithms = []string{
+	jose.A128CBC_HS256, jose.A192CBC_HS384, jose.A256CBC_HS512,
+	jose.A128GCM, jose.A192GCM, jose.A256GCM,
+}
+
+// ContentAlgorithms returns the names of all content encryption
+// algorithms.
+func ContentAlgorithms() []string {
+	return append([]string(nil), contentAlgorithms...)
+}
+
+// KeyAlgorithm describes a JWE key management algorithm.
+type KeyAlgorithm struct {
+	// Name is the JOSE "alg" value, e.g. "RSA-OAEP-256".
+	Name string
+	// KeyType is the JWK "kty" of keys used with the algorithm: "RSA",
+	// "EC" or "oct". PBES2 passwords are "oct" keys as well.
+	KeyType string
+	// CheckKey reports whether key can be used with the algorithm and the
+	// content encryption algorithm enc.
+	CheckKey func(key any, enc string) error
+}
+
+var (
+	ErrUnknownAlg     = errors.New("unknown key management algorithm")
+	ErrUnknownEnc     = errors.New("unknown content encryption algorithm")
+	ErrReservedHeader = errors.New(`"alg", "enc" and "zip" headers are set by the encrypter`)
+)
+
+// Option configures an Encrypter created by NewEncrypter or
+// ParseEncrypter.
+type Option func(*encrypterOptions)
+
+type encrypterOptions struct {
+	enc     string
+	zip     bool
+	headers map[string]any
+	stats   *SizeStats
+	lines   lines.Config
+}
+
+// WithEnc selects the content encryption algorithm, e.g. "A128CBC-HS256",
+// instead of DefaultEnc.
+func WithEnc(enc string) Option {
+	return func(o *encrypterOptions) { o.enc = enc }
+}
+
+// WithZip DEFLATE-compresses payloads before encryption and sets the
+// "zip":"DEF" header (RFC 7516 section 4.1.3).
+func WithZip() Option {
+	return func(o *encrypterOptions) { o.zip = true }
+}
+
+// WithSizeStats records in stats the length of every token next to the
+// length it has with compression toggled, see SizeStats. Each payload is
+// encrypted twice, so this is meant for judging WithZip, not for bulk
+// output.
+func WithSizeStats(stats *SizeStats) Option {
+	return func(o *encrypterOptions) { o.stats = stats }
+}
+
+// WithHeader adds a protected header parameter such as "kid" or "cty" to
+// every token.
+func WithHeader(name string, value any) Option {
+	return func(o *encrypterOptions) {
+		if o.headers == nil {
+			o.headers = map[string]any{}
+		}
+		o.headers[name] = value
+	}
+}
+
+// WithLines sets how EncryptLinesRSAOAEP_A256GCM processes its input, e.g.
+// the number of workers. NewEncrypter and ParseEncrypter ignore it.
+func WithLines(cfg lines.Config) Option {
+	return func(o *encrypterOptions) { o.lines = cfg }
+}
+
+// keyAlgorithms maps JOSE "alg" names to the key management algorithms of
+// RFC 7518 section 4.
+var keyAlgorithms = map[string]KeyAlgorithm{}
+
+func init() {
+	for _, name := range []string{jose.RSA_OAEP, jose.RSA_OAEP_256, jose.RSA1_5} {
+		keyAlgorithms[name] = KeyAlgorithm{Name: name, KeyType: "RSA", CheckKey: checkRSAPublicKey}
+	}
+	for _, name := range []string{jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A192KW, jose.ECDH_ES_A256KW} {
+		keyAlgorithms[name] = KeyAlgorithm{Name: name, KeyType: "EC", CheckKey: checkECPublicKey}
+	}
+	for name, size := range map[string]int{
+		jose.A128KW: 16, jose.A192KW: 24, jose.A256KW: 32,
+		jose.A128GCMKW: 16, jose.A192GCMKW: 24, jose.A256GCMKW: 32,
+	} {
+		keyAlgorithms[name] = KeyAlgorithm{Name: name, KeyType: "oct", CheckKey: checkKeyWrapKey(name, size)}
+	}
+	keyAlgorithms[jose.DIR] = KeyAlgorithm{Name: jose.DIR, KeyType: "oct", CheckKey: checkDirectKey}
+	for _, name := range []string{jose.PBES2_HS256_A128KW, jose.PBES2_HS384_A192KW, jose.PBES2_HS512_A256KW} {
+		keyAlgorithms[name] = KeyAlgorithm{Name: name, KeyType: "oct", CheckKey: checkPassword}
+	}
+}
+
+// LookupKeyAlgorithm returns the key management algorithm with the given
+// JOSE name.
+func LookupKeyAlgorithm(name string) (KeyAlgorithm, bool) {
+	a, ok := keyAlgorithms[name]
+	return a, ok
+}
+
+// KeyAlgorithms returns the names of all key management algorithms in
+// sorted order.
+func KeyAlgorithms() []string {
+	names := make([]string, 0, len(keyAlgorithms))
+	for name := range keyAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isPBES2 reports whether alg derives its key from a password.
+func isPBES2(alg string) bool {
+	return strings.HasPrefix(alg, "PBES2-")
+}
+
+// Encrypter encrypts payloads as compact JWE using a fixed key management
+// algorithm and recipient key.
+type Encrypter struct {
+	alg     string
+	enc     string
+	zip     bool
+	key     any
+	headers map[string]any
+	stats   *SizeStats
+}
+
+// Alg returns the JOSE "alg" header value.
+func (e *Encrypter) Alg() string { return e.alg }
+
+// Enc returns the JOSE "enc" header value.
+func (e *Encrypter) Enc() string { return e.enc }
+
+// NewEncrypter returns an Encrypter for the named key management algorithm
+// and an in-memory key: *rsa.PublicKey for RSA algorithms,
+// *ecdsa.PublicKey for ECDH-ES, the []byte key for AES key wrap and dir,
+// and the []byte password for PBES2.
+func NewEncrypter(alg string, key any, opts ...Option) (*Encrypter, error) {
+	a, ok := LookupKeyAlgorithm(alg)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlg, alg)
+	}
+	o := encrypterOptions{enc: DefaultEnc}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !slices.Contains(contentAlgorithms, o.enc) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEnc, o.enc)
+	}
+	e := &Encrypter{alg: alg, enc: o.enc, zip: o.zip, headers: map[string]any{}, stats: o.stats}
+	if err := a.CheckKey(key, e.enc); err != nil {
+		return nil, err
+	}
+	e.key = key
+	for name, value := range o.headers {
+		if name == "alg" || name == "enc" || name == "zip" {
+			return nil, ErrReservedHeader
+		}
+		e.headers[name] = value
+	}
+	return e, nil
+}
+
+// ParseEncrypter parses encoded key material and returns an Encrypter for
+// it. For RSA and ECDH-ES keyData is a PEM public key, for the other
+// algorithms the raw secret or password. A public JWK or JWK Set is
+// accepted for every algorithm; from a set the key is selected by the
+// "kid" header (see WithHeader), or, without one, the only key usable
+// with the algorithm is taken. A JWK's kid is put into the header unless
+// a kid is set explicitly.
+func ParseEncrypter(alg string, keyData []byte, opts ...Option) (*Encrypter, error) {
+	a, ok := LookupKeyAlgorithm(alg)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlg, alg)
+	}
+	var o encrypterOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// Key-wrap keys and passwords may start with "{" too, so they are only
+	// taken for a JWK when they are one.
+	isJWK := jwk.IsJSON(keyData)
+	if a.KeyType == "oct" {
+		isJWK = jwk.IsJWK(keyData)
+	}
+	if isJWK {
+		key, kid, err := parseJWK(a, keyData, &o)
+		if err != nil {
+			return nil, err
+		}
+		if kid != "" {
+			opts = append([]Option{WithHeader("kid", kid)}, opts...)
+		}
+		return NewEncrypter(alg, key, opts...)
+	}
+	if len(keyData) == 0 {
+		return nil, fmt.Errorf("key must not be empty")
+	}
+	if a.KeyType == "oct" {
+		return NewEncrypter(alg, append([]byte(nil), keyData...), opts...)
+	}
+	key, err := keys.ParsePublicKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncrypter(alg, key, opts...)
+}
+
+// parseJWK selects a public key for algorithm a from a JWK or JWK Set and
+// returns it with its kid.
+func parseJWK(a KeyAlgorithm, data []byte, o *encrypterOptions) (any, string, error) {
+	set, err := jwk.ParseSet(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kid, _ := o.headers["kid"].(string)
+	k, err := set.SelectOne(a.KeyType, a.Name, "enc", kid)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := k.PublicKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("JWK %q: %w", k.Kid, err)
+	}
+	return key, k.Kid, nil
+}
+
+// Encrypt encrypts a single payload and returns a compact JWE.
+func (e *Encrypter) Encrypt(payload []byte) (string, error) {
+	return e.EncryptWithHeaders(payload, nil)
+}
+
+// EncryptWithHeaders is like Encrypt but adds headers to the protected
+// header of this token only, e.g. claims replicated from the payload.
+func (e *Encrypter) EncryptWithHeaders(payload []byte, headers map[string]any) (string, error) {
+	if len(headers) > 0 {
+		merged := make(map[string]any, len(e.headers)+len(headers))
+		for name, value := range e.headers {
+			merged[name] = value
+		}
+		for name, value := range headers {
+			if name == "alg" || name == "enc" || name == "zip" {
+				return "", ErrReservedHeader
+			}
+			merged[name] = value
+		}
+		headers = merged
+	} else {
+		headers = e.headers
+	}
+
+	token, err := e.encrypt(payload, e.zip, headers)
+	if err != nil || e.stats == nil {
+		return token, err
+	}
+	other, err := e.encrypt(payload, !e.zip, headers)
+	if err != nil {
+		return "", err
+	}
+	if e.zip {
+		e.stats.Add(len(token), len(other))
+	} else {
+		e.stats.Add(len(other), len(token))
+	}
+	return token, nil
+}
+
+func (e *Encrypter) encrypt(payload []byte, zip bool, headers map[string]any) (string, error) {
+	jwa, jwe := jwaAlgorithms[e.alg], jweAlgorithms[e.enc]
+	// The key management algorithm adds its own parameters, such as "epk".
+	header := make(map[string]any, len(headers)+3)
+	for name, value := range headers {
+		header[name] = value
+	}
+	header["alg"], header["enc"] = e.alg, e.enc
+	if zip {
+		header["zip"] = jose.DEF
+		payload = jwcAlgorithms[jose.DEF].Compress(payload)
+	}
+	cek, encryptedCek, err := jwa.WrapNewKey(jwe.KeySizeBits(), e.key, header)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("encode header: %w", err)
+	}
+	iv, ciphertext, tag, err := jwe.Encrypt([]byte(compact.Serialize(encoded)), payload, cek)
+	if err != nil {
+		return "", err
+	}
+	return compact.Serialize(encoded, encryptedCek, iv, ciphertext, tag), nil
+}
+
+// EncryptLines reads non-empty lines from r, encrypts each line with e,
+// and writes resulting JWE tokens to w. cfg selects the input format, the
+// number of workers, the output order, how lines become payloads and what
+// happens to lines that fail, see lines.Config. The header of a
+// jsonl-envelope line is added to its token, see EncryptWithHeaders.
+func EncryptLines(r io.Reader, w io.Writer, e *Encrypter, cfg lines.Config) error {
+	return lines.ProcessRecords(r, w, cfg, func(_ int, payload []byte, header map[string]any) (string, error) {
+		return e.EncryptWithHeaders(payload, header)
+	})
+}
+
+// -----------------------------------------------------------------------------
+// Key checks
+// -----------------------------------------------------------------------------
+
+func checkRSAPublicKey(key any, _ string) error {
+	if _, ok := key.(*rsa.PublicKey); !ok {
+		return fmt.Errorf("expected *rsa.PublicKey, got %T", key)
+	}
+	return nil
+}
+
+// checkECPublicKey accepts keys on the NIST curves, the only ones RFC 7518
+// section 4.6 defines for ECDH-ES.
+func checkECPublicKey(key any, _ string) error {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected *ecdsa.PublicKey, got %T", key)
+	}
+	switch name := pub.Curve.Params().Name; name {
+	case "P-256", "P-384", "P-521":
+		return nil
+	default:
+		return fmt.Errorf("ECDH-ES requires a P-256, P-384 or P-521 key, got %s", name)
+	}
+}
+
+// checkKeyWrapKey returns a key check for an AES key wrap algorithm whose
+// key encryption key has exactly size bytes.
+func checkKeyWrapKey(alg string, size int) func(key any, _ string) error {
+	return func(key any, _ string) error {
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("expected []byte key, got %T", key)
+		}
+		if len(secret) != size {
+			return fmt.Errorf("%s requires a %d-bit key, got %d bits", alg, size*8, len(secret)*8)
+		}
+		return nil
+	}
+}
+
+// checkDirectKey accepts a key of the size the content encryption
+// algorithm uses as its CEK.
+func checkDirectKey(key any, enc string) error {
+	secret, ok := key.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte key, got %T", key)
+	}
+	e := jweAlgorithms[enc]
+	if e == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownEnc, enc)
+	}
+	if bits := len(secret) * 8; bits != e.KeySizeBits() {
+		return fmt.Errorf("dir with %s requires a %d-bit key, got %d bits", enc, e.KeySizeBits(), bits)
+	}
+	return nil
+}
+
+func checkPassword(key any, _ string) error {
+	password, ok := key.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte password, got %T", key)
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("password must not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+// TestEncrypterAlgorithms decrypts every alg and enc combination back to
+// the original payload, with Decrypter and with jose2go.
+func TestEncrypterAlgorithms(t *testing.T) {
+	_, rsaPriv := genRSAPublicPEM(t)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	for _, alg := range KeyAlgorithms() {
+		for _, enc := range ContentAlgorithms() {
+			var pub, priv any
+			switch a, _ := LookupKeyAlgorithm(alg); a.KeyType {
+			case "RSA":
+				pub, priv = &rsaPriv.PublicKey, rsaPriv
+			case "EC":
+				pub, priv = &ecPriv.PublicKey, ecPriv
+			default:
+				size := 32
+				switch {
+				case alg == jose.DIR:
+					size = jweAlgorithms[enc].KeySizeBits() / 8
+				case strings.Contains(alg, "128"):
+					size = 16
+				case strings.Contains(alg, "192"):
+					size = 24
+				}
+				pub = bytes.Repeat([]byte{1}, size)
+				priv = pub
+			}
+
+			e, err := NewEncrypter(alg, pub, WithEnc(enc), WithHeader("cty", "JWT"))
+			if err != nil {
+				t.Fatalf("%s/%s: NewEncrypter: %v", alg, enc, err)
+			}
+			token, err := e.Encrypt([]byte(`{"sub":"payload"}`))
+			if err != nil {
+				t.Fatalf("%s/%s: Encrypt: %v", alg, enc, err)
+			}
+
+			k, err := jwk.FromPrivateKey(priv)
+			if err != nil {
+				t.Fatalf("%s/%s: FromPrivateKey: %v", alg, enc, err)
+			}
+			d, err := NewDecrypter(mustJSON(t, k), nil)
+			if err != nil {
+				t.Fatalf("%s/%s: NewDecrypter: %v", alg, enc, err)
+			}
+			dec, err := d.Decrypt(token)
+			if err != nil {
+				t.Fatalf("%s/%s: Decrypt: %v", alg, enc, err)
+			}
+			header := string(dec.Header)
+			if string(dec.Plaintext) != `{"sub":"payload"}` || !strings.Contains(header, `"alg":"`+alg+`"`) ||
+				!strings.Contains(header, `"enc":"`+enc+`"`) || !strings.Contains(header, `"cty":"JWT"`) {
+				t.Fatalf("%s/%s: unexpected result: %s %s", alg, enc, header, dec.Plaintext)
+			}
+
+			// jose2go decrypts the token too; it takes PBES2 passwords as
+			// strings.
+			if isPBES2(alg) {
+				priv = string(priv.([]byte))
+			}
+			if payload, _, err := jose.Decode(token, priv); err != nil || payload != `{"sub":"payload"}` {
+				t.Fatalf("%s/%s: jose.Decode: %q, %v", alg, enc, payload, err)
+			}
+		}
+	}
+}
+
+func TestEncrypterKeyChecks(t *testing.T) {
+	pubPEM, rsaPriv := genRSAPublicPEM(t)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	cases := []struct {
+		alg  string
+		key  any
+		want string
+	}{
+		{jose.RSA_OAEP_256, &ecPriv.PublicKey, "expected *rsa.PublicKey"},
+		{jose.RSA_OAEP, rsaPriv, "expected *rsa.PublicKey"},
+		{jose.ECDH_ES_A256KW, &rsaPriv.PublicKey, "expected *ecdsa.PublicKey"},
+		{jose.A128KW, make([]byte, 32), "A128KW requires a 128-bit key, got 256 bits"},
+		{jose.A256GCMKW, "secret", "expected []byte key"},
+		{jose.DIR, make([]byte, 16), "dir with A256GCM requires a 256-bit key, got 128 bits"},
+		{jose.PBES2_HS256_A128KW, []byte{}, "password must not be empty"},
+	}
+	for _, c := range cases {
+		if _, err := NewEncrypter(c.alg, c.key); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s with %T: expected %q, got %v", c.alg, c.key, c.want, err)
+		}
+	}
+
+	if _, err := NewEncrypter("RSA-OAEP-1024", &rsaPriv.PublicKey); !errors.Is(err, ErrUnknownAlg) {
+		t.Fatalf("expected ErrUnknownAlg, got %v", err)
+	}
+	if _, err := NewEncrypter(jose.DIR, make([]byte, 32), WithEnc(jose.A256CBC_HS512)); err == nil ||
+		!strings.Contains(err.Error(), "dir with A256CBC-HS512 requires a 512-bit key") {
+		t.Fatalf("expected dir key size error for A256CBC-HS512, got %v", err)
+	}
+	if _, err := NewEncrypter(jose.RSA_OAEP, &rsaPriv.PublicKey, WithEnc("A512GCM")); !errors.Is(err, ErrUnknownEnc) {
+		t.Fatalf("expected ErrUnknownEnc, got %v", err)
+	}
+	if _, err := NewEncrypter(jose.RSA_OAEP, &rsaPriv.PublicKey, WithHeader("enc", "A128GCM")); !errors.Is(err, ErrReservedHeader) {
+		t.Fatalf("expected ErrReservedHeader, got %v", err)
+	}
+	if _, err := ParseEncrypter(jose.ECDH_ES, pubPEM); err == nil || !strings.Contains(err.Error(), "expected *ecdsa.PublicKey") {
+		t.Fatalf("expected key type error for RSA PEM with ECDH-ES, got %v", err)
+	}
+	if _, err := ParseEncrypter(jose.A256KW, nil); err == nil {
+		t.Fatalf("expected error for empty key")
+	}
+}
+
+func TestParseEncrypter(t *testing.T) {
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&ecPriv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	e, err := ParseEncrypter(jose.ECDH_ES_A256KW, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseEncrypter PEM: %v", err)
+	}
+	if e.Alg() != jose.ECDH_ES_A256KW {
+		t.Fatalf("unexpected alg %q", e.Alg())
+	}
+
+	if _, err := ParseEncrypter(jose.PBES2_HS512_A256KW, []byte("correct horse")); err != nil {
+		t.Fatalf("ParseEncrypter password: %v", err)
+	}
+	// Passwords and key-wrap keys starting with "{" are not JWKs.
+	e, err = ParseEncrypter(jose.PBES2_HS256_A128KW, []byte("{my} password"))
+	if err != nil {
+		t.Fatalf("ParseEncrypter password with brace: %v", err)
+	}
+	token, err := e.Encrypt([]byte("p"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if payload, _, err := jose.Decode(token, "{my} password"); err != nil || payload != "p" {
+		t.Fatalf("jose.Decode: %q, %v", payload, err)
+	}
+	if _, err := ParseEncrypter(jose.A128KW, []byte("{0123456789abcd}")); err != nil {
+		t.Fatalf("ParseEncrypter key-wrap key with brace: %v", err)
+	}
+
+	ecJWK, err := jwk.FromPublicKey(ecPriv)
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	ecJWK.Kid = "mobile"
+	ecJWK.Use = "enc"
+	sigJWK := *ecJWK
+	sigJWK.Kid, sigJWK.Use = "sig", "sig"
+	set := mustJSON(t, jwk.Set{Keys: []jwk.Key{sigJWK, *ecJWK}})
+
+	e, err = ParseEncrypter(jose.ECDH_ES_A256KW, set)
+	if err != nil {
+		t.Fatalf("ParseEncrypter JWK Set: %v", err)
+	}
+	token, err = e.Encrypt([]byte("p"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	priv, err := jwk.FromPrivateKey(ecPriv)
+	if err != nil {
+		t.Fatalf("FromPrivateKey: %v", err)
+	}
+	priv.Kid = "mobile"
+	d, err := NewDecrypter(mustJSON(t, priv), nil)
+	if err != nil {
+		t.Fatalf("NewDecrypter: %v", err)
+	}
+	dec, err := d.Decrypt(token)
+	if err != nil || !strings.Contains(string(dec.Header), `"kid":"mobile"`) {
+		t.Fatalf("expected kid from JWK, got %v", err)
+	}
+
+	if _, err := ParseEncrypter(jose.ECDH_ES, set, WithHeader("kid", "nope")); !errors.Is(err, jwk.ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for unknown kid, got %v", err)
+	}
+	if _, err := ParseEncrypter(jose.RSA_OAEP, set); !errors.Is(err, jwk.ErrNoKey) {
+		t.Fatalf("expected ErrNoKey without RSA keys, got %v", err)
+	}
+}
+
+func TestEncryptLines(t *testing.T) {
+	secret := bytes.Repeat([]byte{2}, 32)
+	e, err := NewEncrypter(jose.A256KW, secret)
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := EncryptLines(strings.NewReader("line1\n\nline2\n"), &buf, e, lines.Config{Workers: 2}); err != nil {
+		t.Fatalf("EncryptLines: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(lines))
+	}
+	for i, want := range []string{"line1", "line2"} {
+		payload, _, err := jose.Decode(lines[i], secret)
+		if err != nil || payload != want {
+			t.Fatalf("token %d: %q, %v", i, payload, err)
+		}
+	}
+}
+
+func TestEncrypterZip(t *testing.T) {
+	secret := bytes.Repeat([]byte{3}, 32)
+	payload := []byte(`{"groups":["` + strings.Repeat("admin", 200) + `"]}`)
+
+	var stats SizeStats
+	e, err := NewEncrypter(jose.DIR, secret, WithZip(), WithSizeStats(&stats))
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	token, err := e.Encrypt(payload)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	got, hdr, err := jose.Decode(token, secret)
+	if err != nil || got != string(payload) || hdr["zip"] != jose.DEF {
+		t.Fatalf("jose.Decode: %v, %v", hdr, err)
+	}
+	if stats.tokens != 1 || stats.smaller != 1 || stats.compressed != int64(len(token)) || stats.uncompressed <= stats.compressed {
+		t.Fatalf("unexpected stats: %d tokens, %d/%d bytes", stats.tokens, stats.compressed, stats.uncompressed)
+	}
+
+	// Without WithZip the stats still compare both variants.
+	e, err = NewEncrypter(jose.DIR, secret, WithSizeStats(&stats))
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	if token, err = e.Encrypt(payload); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, hdr, err := jose.Decode(token, secret); err != nil || hdr["zip"] != nil {
+		t.Fatalf("unexpected zip header without WithZip: %v, %v", hdr, err)
+	}
+	if stats.tokens != 2 || stats.uncompressed != 2*int64(len(token)) {
+		t.Fatalf("unexpected stats: %d tokens, %d/%d bytes", stats.tokens, stats.compressed, stats.uncompressed)
+	}
+
+	if _, err := NewEncrypter(jose.DIR, secret, WithHeader("zip", "DEF")); !errors.Is(err, ErrReservedHeader) {
+		t.Fatalf("expected ErrReservedHeader for zip header, got %v", err)
+	}
+}
+
+func TestEncryptWithHeaders(t *testing.T) {
+	secret := bytes.Repeat([]byte{4}, 16)
+	e, err := NewEncrypter(jose.A128KW, secret, WithHeader("kid", "k1"))
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	token, err := e.EncryptWithHeaders([]byte("p"), map[string]any{"cty": "JWT", "sub": "alice"})
+	if err != nil {
+		t.Fatalf("EncryptWithHeaders: %v", err)
+	}
+	_, hdr, err := jose.Decode(token, secret)
+	if err != nil || hdr["kid"] != "k1" || hdr["cty"] != "JWT" || hdr["sub"] != "alice" {
+		t.Fatalf("unexpected header: %v, %v", hdr, err)
+	}
+
+	// Per-token headers must not leak into the next token.
+	if token, err = e.Encrypt([]byte("p")); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, hdr, err := jose.Decode(token, secret); err != nil || hdr["sub"] != nil {
+		t.Fatalf("unexpected header: %v, %v", hdr, err)
+	}
+
+	if _, err := e.EncryptWithHeaders([]byte("p"), map[string]any{"alg": "none"}); !errors.Is(err, ErrReservedHeader) {
+		t.Fatalf("expected ErrReservedHeader, got %v", err)
+	}
+}
@@ -0,0 +1,482 @@
+// SPDX-License-Identifier: MIT
+
+package encrypt
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+	aeskw "github.com/dvsekhvalnov/jose2go/aes"
+	"github.com/dvsekhvalnov/jose2go/base64url"
+)
+
+// The algorithms of RFC 7518 sections 4 and 5 and the DEFLATE compression
+// of RFC 7516 as explicit instances of jose2go's interfaces. jose2go
+// implements them too, but only behind its global registry, which offers
+// no lookup.
+
+// jwaAlgorithms, jweAlgorithms and jwcAlgorithms map JOSE names to the key
+// management, content encryption and compression algorithms.
+var (
+	jwaAlgorithms = byName[jose.JwaAlgorithm](
+		&rsaOAEPAlgorithm{name: jose.RSA_OAEP, hash: crypto.SHA1},
+		&rsaOAEPAlgorithm{name: jose.RSA_OAEP_256, hash: crypto.SHA256},
+		&rsa15Algorithm{},
+		&ecdhAlgorithm{name: jose.ECDH_ES},
+		&ecdhAlgorithm{name: jose.ECDH_ES_A128KW, kw: &aesKWAlgorithm{name: jose.A128KW, bits: 128}},
+		&ecdhAlgorithm{name: jose.ECDH_ES_A192KW, kw: &aesKWAlgorithm{name: jose.A192KW, bits: 192}},
+		&ecdhAlgorithm{name: jose.ECDH_ES_A256KW, kw: &aesKWAlgorithm{name: jose.A256KW, bits: 256}},
+		&aesKWAlgorithm{name: jose.A128KW, bits: 128},
+		&aesKWAlgorithm{name: jose.A192KW, bits: 192},
+		&aesKWAlgorithm{name: jose.A256KW, bits: 256},
+		&aesGCMKWAlgorithm{name: jose.A128GCMKW, bits: 128},
+		&aesGCMKWAlgorithm{name: jose.A192GCMKW, bits: 192},
+		&aesGCMKWAlgorithm{name: jose.A256GCMKW, bits: 256},
+		&directAlgorithm{},
+		&pbes2Algorithm{name: jose.PBES2_HS256_A128KW, hash: crypto.SHA256, kw: &aesKWAlgorithm{name: jose.A128KW, bits: 128}, maxIterations: 1300000},
+		&pbes2Algorithm{name: jose.PBES2_HS384_A192KW, hash: crypto.SHA384, kw: &aesKWAlgorithm{name: jose.A192KW, bits: 192}, maxIterations: 950000},
+		&pbes2Algorithm{name: jose.PBES2_HS512_A256KW, hash: crypto.SHA512, kw: &aesKWAlgorithm{name: jose.A256KW, bits: 256}, maxIterations: 600000},
+	)
+	jweAlgorithms = byName[jose.JweEncryption](
+		&aesCBCHMACEncryption{name: jose.A128CBC_HS256, bits: 256, hash: crypto.SHA256},
+		&aesCBCHMACEncryption{name: jose.A192CBC_HS384, bits: 384, hash: crypto.SHA384},
+		&aesCBCHMACEncryption{name: jose.A256CBC_HS512, bits: 512, hash: crypto.SHA512},
+		&aesGCMEncryption{name: jose.A128GCM, bits: 128},
+		&aesGCMEncryption{name: jose.A192GCM, bits: 192},
+		&aesGCMEncryption{name: jose.A256GCM, bits: 256},
+	)
+	jwcAlgorithms = byName[jose.JwcAlgorithm](&deflateCompression{maxSize: 250 << 10})
+)
+
+func byName[T interface{ Name() string }](algs ...T) map[string]T {
+	m := make(map[string]T, len(algs))
+	for _, a := range algs {
+		m[a.Name()] = a
+	}
+	return m
+}
+
+// randomBytes returns n bytes from crypto/rand.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// symmetricKey returns key as a []byte of bits bits.
+func symmetricKey(alg string, key any, bits int) ([]byte, error) {
+	b, ok := key.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected []byte key, got %T", alg, key)
+	}
+	if len(b)*8 != bits {
+		return nil, fmt.Errorf("%s: expected a %d-bit key, got %d bits", alg, bits, len(b)*8)
+	}
+	return b, nil
+}
+
+// -----------------------------------------------------------------------------
+// Key management (RFC 7518 section 4)
+// -----------------------------------------------------------------------------
+
+// rsaOAEPAlgorithm implements jose.JwaAlgorithm for RSA-OAEP (SHA-1) and
+// RSA-OAEP-256.
+type rsaOAEPAlgorithm struct {
+	name string
+	hash crypto.Hash
+}
+
+func (a *rsaOAEPAlgorithm) Name() string { return a.name }
+
+func (a *rsaOAEPAlgorithm) WrapNewKey(cekSizeBits int, key interface{}, _ map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s WrapNewKey: expected *rsa.PublicKey, got %T", a.name, key)
+	}
+	if cek, err = randomBytes(cekSizeBits / 8); err != nil {
+		return nil, nil, err
+	}
+	if encryptedCek, err = rsa.EncryptOAEP(a.hash.New(), rand.Reader, pub, cek, nil); err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedCek, nil
+}
+
+func (a *rsaOAEPAlgorithm) Unwrap(encryptedCek []byte, key interface{}, _ int, _ map[string]interface{}) ([]byte, error) {
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s Unwrap: expected *rsa.PrivateKey, got %T", a.name, key)
+	}
+	return rsa.DecryptOAEP(a.hash.New(), nil, priv, encryptedCek, nil)
+}
+
+// rsa15Algorithm implements jose.JwaAlgorithm for RSA1_5. Unwrap returns
+// a random key instead of an error when decryption fails, so that a wrong
+// key shows up only when the content fails to decrypt (RFC 7516 section
+// 11.5).
+type rsa15Algorithm struct{}
+
+func (a *rsa15Algorithm) Name() string { return jose.RSA1_5 }
+
+func (a *rsa15Algorithm) WrapNewKey(cekSizeBits int, key interface{}, _ map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("RSA1_5 WrapNewKey: expected *rsa.PublicKey, got %T", key)
+	}
+	if cek, err = randomBytes(cekSizeBits / 8); err != nil {
+		return nil, nil, err
+	}
+	if encryptedCek, err = rsa.EncryptPKCS1v15(rand.Reader, pub, cek); err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedCek, nil
+}
+
+func (a *rsa15Algorithm) Unwrap(encryptedCek []byte, key interface{}, cekSizeBits int, _ map[string]interface{}) ([]byte, error) {
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("RSA1_5 Unwrap: expected *rsa.PrivateKey, got %T", key)
+	}
+	cek, err := randomBytes(cekSizeBits / 8)
+	if err != nil {
+		return nil, err
+	}
+	if err := rsa.DecryptPKCS1v15SessionKey(nil, priv, encryptedCek, cek); err != nil {
+		return nil, err
+	}
+	return cek, nil
+}
+
+// aesKWAlgorithm implements jose.JwaAlgorithm for A128KW, A192KW and
+// A256KW (RFC 3394 key wrap with a bits-bit key).
+type aesKWAlgorithm struct {
+	name string
+	bits int
+}
+
+func (a *aesKWAlgorithm) Name() string { return a.name }
+
+func (a *aesKWAlgorithm) WrapNewKey(cekSizeBits int, key interface{}, _ map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	kek, err := symmetricKey(a.name, key, a.bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cek, err = randomBytes(cekSizeBits / 8); err != nil {
+		return nil, nil, err
+	}
+	if encryptedCek, err = aeskw.KeyWrap(cek, kek); err != nil {
+		return nil, nil, err
+	}
+	return cek, encryptedCek, nil
+}
+
+func (a *aesKWAlgorithm) Unwrap(encryptedCek []byte, key interface{}, _ int, _ map[string]interface{}) ([]byte, error) {
+	kek, err := symmetricKey(a.name, key, a.bits)
+	if err != nil {
+		return nil, err
+	}
+	return aeskw.KeyUnwrap(encryptedCek, kek)
+}
+
+// aesGCMKWAlgorithm implements jose.JwaAlgorithm for A128GCMKW, A192GCMKW
+// and A256GCMKW. The IV and tag go into the "iv" and "tag" headers.
+type aesGCMKWAlgorithm struct {
+	name string
+	bits int
+}
+
+func (a *aesGCMKWAlgorithm) Name() string { return a.name }
+
+func (a *aesGCMKWAlgorithm) WrapNewKey(cekSizeBits int, key interface{}, header map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	kek, err := symmetricKey(a.name, key, a.bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cek, err = randomBytes(cekSizeBits / 8); err != nil {
+		return nil, nil, err
+	}
+	iv, ciphertext, tag, err := gcmSeal(kek, cek, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	header["iv"] = base64url.Encode(iv)
+	header["tag"] = base64url.Encode(tag)
+	return cek, ciphertext, nil
+}
+
+func (a *aesGCMKWAlgorithm) Unwrap(encryptedCek []byte, key interface{}, _ int, header map[string]interface{}) ([]byte, error) {
+	kek, err := symmetricKey(a.name, key, a.bits)
+	if err != nil {
+		return nil, err
+	}
+	iv, tag := headerBytes(header, "iv"), headerBytes(header, "tag")
+	if len(iv) == 0 || len(tag) == 0 {
+		return nil, fmt.Errorf(`%s Unwrap: missing or invalid "iv" or "tag" header`, a.name)
+	}
+	return gcmOpen(kek, iv, encryptedCek, tag, nil)
+}
+
+// directAlgorithm implements jose.JwaAlgorithm for dir: the key is the
+// content encryption key and the encrypted key is empty.
+type directAlgorithm struct{}
+
+func (a *directAlgorithm) Name() string { return jose.DIR }
+
+func (a *directAlgorithm) WrapNewKey(cekSizeBits int, key interface{}, _ map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	if cek, err = symmetricKey(jose.DIR, key, cekSizeBits); err != nil {
+		return nil, nil, err
+	}
+	return cek, nil, nil
+}
+
+func (a *directAlgorithm) Unwrap(encryptedCek []byte, key interface{}, cekSizeBits int, _ map[string]interface{}) ([]byte, error) {
+	if len(encryptedCek) != 0 {
+		return nil, errors.New("dir Unwrap: expected an empty encrypted key")
+	}
+	return symmetricKey(jose.DIR, key, cekSizeBits)
+}
+
+// pbes2Algorithm implements jose.JwaAlgorithm for PBES2-HS256+A128KW,
+// PBES2-HS384+A192KW and PBES2-HS512+A256KW. The key is the []byte
+// password. Unwrap rejects iteration counts above maxIterations, which
+// would let a token make decryption arbitrarily slow.
+type pbes2Algorithm struct {
+	name          string
+	hash          crypto.Hash
+	kw            *aesKWAlgorithm
+	maxIterations int
+}
+
+// pbes2Iterations and pbes2SaltSize are the "p2c" and the size of the "p2s"
+// of new tokens.
+const (
+	pbes2Iterations = 8192
+	pbes2SaltSize   = 12
+)
+
+func (a *pbes2Algorithm) Name() string { return a.name }
+
+func (a *pbes2Algorithm) WrapNewKey(cekSizeBits int, key interface{}, header map[string]interface{}) (cek, encryptedCek []byte, err error) {
+	password, ok := key.([]byte)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s WrapNewKey: expected []byte password, got %T", a.name, key)
+	}
+	salt, err := randomBytes(pbes2SaltSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	header["p2s"] = base64url.Encode(salt)
+	header["p2c"] = pbes2Iterations
+	kek, err := a.deriveKey(password, salt, pbes2Iterations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.kw.WrapNewKey(cekSizeBits, kek, header)
+}
+
+func (a *pbes2Algorithm) Unwrap(encryptedCek []byte, key interface{}, cekSizeBits int, header map[string]interface{}) ([]byte, error) {
+	password, ok := key.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s Unwrap: expected []byte password, got %T", a.name, key)
+	}
+	p2c, _ := header["p2c"].(float64)
+	if p2c < 1 || p2c > float64(a.maxIterations) || p2c != float64(int(p2c)) {
+		return nil, fmt.Errorf(`%s Unwrap: "p2c" header must be an integer from 1 to %d`, a.name, a.maxIterations)
+	}
+	salt := headerBytes(header, "p2s")
+	if len(salt) == 0 {
+		return nil, fmt.Errorf(`%s Unwrap: missing or invalid "p2s" header`, a.name)
+	}
+	kek, err := a.deriveKey(password, salt, int(p2c))
+	if err != nil {
+		return nil, err
+	}
+	return a.kw.Unwrap(encryptedCek, kek, cekSizeBits, header)
+}
+
+// deriveKey runs PBKDF2 over password with the salt of RFC 7518 section
+// 4.8.1.1: the algorithm name, a zero byte and the "p2s" value.
+func (a *pbes2Algorithm) deriveKey(password, salt []byte, iterations int) ([]byte, error) {
+	input := append(append([]byte(a.name), 0), salt...)
+	return pbkdf2.Key(a.hash.New, string(password), input, iterations, a.kw.bits/8)
+}
+
+// -----------------------------------------------------------------------------
+// Content encryption (RFC 7518 section 5)
+// -----------------------------------------------------------------------------
+
+// aesGCMEncryption implements jose.JweEncryption for A128GCM, A192GCM and
+// A256GCM.
+type aesGCMEncryption struct {
+	name string
+	bits int
+}
+
+func (e *aesGCMEncryption) Name() string     { return e.name }
+func (e *aesGCMEncryption) KeySizeBits() int { return e.bits }
+
+func (e *aesGCMEncryption) Encrypt(aad, plaintext, cek []byte) (iv, ciphertext, tag []byte, err error) {
+	if _, err := symmetricKey(e.name, cek, e.bits); err != nil {
+		return nil, nil, nil, err
+	}
+	return gcmSeal(cek, plaintext, aad)
+}
+
+func (e *aesGCMEncryption) Decrypt(aad, cek, iv, ciphertext, tag []byte) ([]byte, error) {
+	if _, err := symmetricKey(e.name, cek, e.bits); err != nil {
+		return nil, err
+	}
+	return gcmOpen(cek, iv, ciphertext, tag, aad)
+}
+
+// gcmSeal encrypts plaintext with AES-GCM under key and a random 96-bit IV.
+func gcmSeal(key, plaintext, aad []byte) (iv, ciphertext, tag []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if iv, err = randomBytes(gcm.NonceSize()); err != nil {
+		return nil, nil, nil, err
+	}
+	sealed := gcm.Seal(nil, iv, plaintext, aad)
+	n := len(sealed) - gcm.Overhead()
+	return iv, sealed[:n], sealed[n:], nil
+}
+
+// gcmOpen decrypts and authenticates an AES-GCM ciphertext.
+func gcmOpen(key, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return nil, errors.New("AES-GCM: invalid IV or tag size")
+	}
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(append(sealed, ciphertext...), tag...)
+	return gcm.Open(nil, iv, sealed, aad)
+}
+
+// aesCBCHMACEncryption implements jose.JweEncryption for A128CBC-HS256,
+// A192CBC-HS384 and A256CBC-HS512 (RFC 7518 section 5.2). The first half
+// of the bits-bit key is the MAC key, the second half the AES key.
+type aesCBCHMACEncryption struct {
+	name string
+	bits int
+	hash crypto.Hash
+}
+
+func (e *aesCBCHMACEncryption) Name() string     { return e.name }
+func (e *aesCBCHMACEncryption) KeySizeBits() int { return e.bits }
+
+func (e *aesCBCHMACEncryption) Encrypt(aad, plaintext, cek []byte) (iv, ciphertext, tag []byte, err error) {
+	if _, err := symmetricKey(e.name, cek, e.bits); err != nil {
+		return nil, nil, nil, err
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if iv, err = randomBytes(aes.BlockSize); err != nil {
+		return nil, nil, nil, err
+	}
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext = append(append([]byte(nil), plaintext...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return iv, ciphertext, e.tag(macKey, aad, iv, ciphertext), nil
+}
+
+func (e *aesCBCHMACEncryption) Decrypt(aad, cek, iv, ciphertext, tag []byte) ([]byte, error) {
+	if _, err := symmetricKey(e.name, cek, e.bits); err != nil {
+		return nil, err
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	if !hmac.Equal(tag, e.tag(macKey, aad, iv, ciphertext)) {
+		return nil, fmt.Errorf("%s: authentication tag mismatch", e.name)
+	}
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%s: invalid IV or ciphertext size", e.name)
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("%s: invalid padding", e.name)
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// tag returns the first half of the HMAC over the AAD, IV, ciphertext and
+// the AAD length in bits.
+func (e *aesCBCHMACEncryption) tag(macKey, aad, iv, ciphertext []byte) []byte {
+	mac := hmac.New(e.hash.New, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(aad))*8))
+	return mac.Sum(nil)[:e.hash.Size()/2]
+}
+
+// -----------------------------------------------------------------------------
+// Compression (RFC 7516 section 4.1.3)
+// -----------------------------------------------------------------------------
+
+// deflateCompression implements jose.JwcAlgorithm for DEF. Decompress
+// fails beyond maxSize bytes, so that small tokens cannot expand into
+// large payloads.
+type deflateCompression struct {
+	maxSize int64
+}
+
+func (c *deflateCompression) Name() string { return jose.DEF }
+
+func (c *deflateCompression) Compress(plaintext []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(plaintext)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (c *deflateCompression) Decompress(compressed []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+	plaintext, err := io.ReadAll(io.LimitReader(r, c.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(plaintext)) > c.maxSize {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes", c.maxSize)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: MIT
+
+package encrypt
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SizeStats accumulates the lengths of tokens encrypted with and without
+// DEFLATE compression, see WithSizeStats. It is safe for concurrent use.
+type SizeStats struct {
+	mu           sync.Mutex
+	tokens       int
+	compressed   int64
+	uncompressed int64
+	smaller      int
+}
+
+// Add records the lengths of one token with and without compression.
+func (s *SizeStats) Add(compressed, uncompressed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens++
+	s.compressed += int64(compressed)
+	s.uncompressed += int64(uncompressed)
+	if compressed < uncompressed {
+		s.smaller++
+	}
+}
+
+// WriteReport writes a summary of the recorded lengths to w, e.g.
+//
+//	tokens:       1000
+//	uncompressed: 1843200 bytes (avg 1843.2)
+//	zip=DEF:      1105920 bytes (avg 1105.9, -40.0%)
+//	smaller with zip=DEF: 1000 of 1000 tokens
+func (s *SizeStats) WriteReport(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens == 0 {
+		_, err := fmt.Fprintln(w, "tokens:       0")
+		return err
+	}
+	n := float64(s.tokens)
+	change := 100 * float64(s.compressed-s.uncompressed) / float64(s.uncompressed)
+	_, err := fmt.Fprintf(w, "tokens:       %d\nuncompressed: %d bytes (avg %.1f)\nzip=DEF:      %d bytes (avg %.1f, %+.1f%%)\nsmaller with zip=DEF: %d of %d tokens\n",
+		s.tokens, s.uncompressed, float64(s.uncompressed)/n, s.compressed, float64(s.compressed)/n, change, s.smaller, s.tokens)
+	return err
+}
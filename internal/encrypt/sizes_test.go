@@ -0,0 +1,28 @@
+package encrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSizeStatsReport(t *testing.T) {
+	var s SizeStats
+	var buf bytes.Buffer
+	if err := s.WriteReport(&buf); err != nil || buf.String() != "tokens:       0\n" {
+		t.Fatalf("unexpected empty report: %q, %v", buf.String(), err)
+	}
+
+	s.Add(600, 1000)
+	s.Add(1100, 1000)
+	buf.Reset()
+	if err := s.WriteReport(&buf); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+	want := "tokens:       2\n" +
+		"uncompressed: 2000 bytes (avg 1000.0)\n" +
+		"zip=DEF:      1700 bytes (avg 850.0, -15.0%)\n" +
+		"smaller with zip=DEF: 1 of 2 tokens\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
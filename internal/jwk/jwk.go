@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: MIT
+
+// Package jwk converts keys to and from JSON Web Keys (RFC 7517) and
+// computes JWK thumbprints (RFC 7638).
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Key is a JSON Web Key. Only the members used by this project are
+// represented.
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// oct
+	K string `json:"k,omitempty"`
+
+	// Private members: d for EC, OKP and RSA; the rest for RSA only.
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	Dp string `json:"dp,omitempty"`
+	Dq string `json:"dq,omitempty"`
+	Qi string `json:"qi,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// publicOf returns the public part of a private key; other keys are
+// returned unchanged.
+func publicOf(key any) any {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return key
+}
+
+// curveSize returns the JWK "crv" name and coordinate size in bytes for
+// an EC curve name as reported by elliptic.Curve.Params.
+func curveSize(name string) (string, int, error) {
+	switch name {
+	case "P-256":
+		return "P-256", 32, nil
+	case "P-384":
+		return "P-384", 48, nil
+	case "P-521":
+		return "P-521", 66, nil
+	case "secp256k1":
+		return "secp256k1", 32, nil
+	}
+	return "", 0, fmt.Errorf("unsupported EC curve %q", name)
+}
+
+// FromPublicKey returns the public JWK of key. Private keys are reduced to
+// their public part; an HMAC secret ([]byte) becomes an "oct" key.
+func FromPublicKey(key any) (*Key, error) {
+	switch k := publicOf(key).(type) {
+	case *rsa.PublicKey:
+		return &Key{
+			Kty: "RSA",
+			N:   b64.EncodeToString(k.N.Bytes()),
+			E:   b64.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		crv, size, err := curveSize(k.Curve.Params().Name)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{
+			Kty: "EC",
+			Crv: crv,
+			X:   b64.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			Y:   b64.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &Key{Kty: "OKP", Crv: "Ed25519", X: b64.EncodeToString(k)}, nil
+	case []byte:
+		if len(k) == 0 {
+			return nil, fmt.Errorf("empty secret")
+		}
+		return &Key{Kty: "oct", K: b64.EncodeToString(k)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// FromPrivateKey returns the private JWK of key, i.e. the public JWK plus
+// the private members. An HMAC secret ([]byte) becomes an "oct" key.
+func FromPrivateKey(key any) (*Key, error) {
+	k, err := FromPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	switch priv := key.(type) {
+	case *rsa.PrivateKey:
+		if len(priv.Primes) != 2 {
+			return nil, fmt.Errorf("multi-prime RSA keys are not supported")
+		}
+		priv.Precompute()
+		k.D = b64.EncodeToString(priv.D.Bytes())
+		k.P = b64.EncodeToString(priv.Primes[0].Bytes())
+		k.Q = b64.EncodeToString(priv.Primes[1].Bytes())
+		k.Dp = b64.EncodeToString(priv.Precomputed.Dp.Bytes())
+		k.Dq = b64.EncodeToString(priv.Precomputed.Dq.Bytes())
+		k.Qi = b64.EncodeToString(priv.Precomputed.Qinv.Bytes())
+	case *ecdsa.PrivateKey:
+		_, size, err := curveSize(priv.Curve.Params().Name)
+		if err != nil {
+			return nil, err
+		}
+		k.D = b64.EncodeToString(priv.D.FillBytes(make([]byte, size)))
+	case ed25519.PrivateKey:
+		k.D = b64.EncodeToString(priv.Seed())
+	case []byte:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return k, nil
+}
+
+// Thumbprint returns the base64url-encoded RFC 7638 SHA-256 thumbprint of k,
+// computed over the required members of its key type.
+func (k *Key) Thumbprint() (string, error) {
+	var members any
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	case "oct":
+		members = struct {
+			K   string `json:"k"`
+			Kty string `json:"kty"`
+		}{k.K, k.Kty}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64.EncodeToString(sum[:]), nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the public part of
+// key, see FromPublicKey.
+func Thumbprint(key any) (string, error) {
+	k, err := FromPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return k.Thumbprint()
+}
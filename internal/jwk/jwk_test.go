@@ -0,0 +1,103 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestThumbprintRFC7638Example(t *testing.T) {
+	// Example key and thumbprint from RFC 7638, section 3.1.
+	k := &Key{
+		Kty: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1" +
+			"n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: "RS256",
+		Kid: "2011-04-29",
+	}
+	got, err := k.Thumbprint()
+	if err != nil {
+		t.Fatalf("Thumbprint error: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestThumbprintKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey Ed25519: %v", err)
+	}
+
+	pairs := []struct {
+		name         string
+		priv, pub    any
+		kty, crvWant string
+	}{
+		{"RSA", rsaKey, &rsaKey.PublicKey, "RSA", ""},
+		{"EC", ecKey, &ecKey.PublicKey, "EC", "P-521"},
+		{"OKP", edKey, edPub, "OKP", "Ed25519"},
+		{"oct", []byte("secret"), []byte("secret"), "oct", ""},
+	}
+	for _, p := range pairs {
+		t.Run(p.name, func(t *testing.T) {
+			fromPriv, err := Thumbprint(p.priv)
+			if err != nil {
+				t.Fatalf("Thumbprint(private) error: %v", err)
+			}
+			fromPub, err := Thumbprint(p.pub)
+			if err != nil {
+				t.Fatalf("Thumbprint(public) error: %v", err)
+			}
+			if fromPriv != fromPub || len(fromPriv) != 43 {
+				t.Fatalf("unexpected thumbprints %q / %q", fromPriv, fromPub)
+			}
+			k, err := FromPublicKey(p.priv)
+			if err != nil {
+				t.Fatalf("FromPublicKey error: %v", err)
+			}
+			if k.Kty != p.kty || k.Crv != p.crvWant {
+				t.Fatalf("unexpected JWK: %+v", k)
+			}
+		})
+	}
+
+	if len(mustKey(t, ecKey).X) != 88 {
+		t.Fatalf("P-521 coordinates must be padded to 66 bytes")
+	}
+}
+
+func mustKey(t *testing.T, key any) *Key {
+	t.Helper()
+	k, err := FromPublicKey(key)
+	if err != nil {
+		t.Fatalf("FromPublicKey error: %v", err)
+	}
+	return k
+}
+
+func TestThumbprintErrors(t *testing.T) {
+	if _, err := Thumbprint("nope"); err == nil {
+		t.Fatalf("expected error for unsupported key type")
+	}
+	if _, err := Thumbprint([]byte{}); err == nil {
+		t.Fatalf("expected error for empty secret")
+	}
+	if _, err := (&Key{Kty: "XYZ"}).Thumbprint(); err == nil {
+		t.Fatalf("expected error for unknown kty")
+	}
+}
@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: MIT
+
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Set is a JWK Set (RFC 7517 section 5).
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+var (
+	ErrNotPrivate = errors.New("JWK has no private key members")
+	ErrNoKey      = errors.New("no matching key in JWK Set")
+)
+
+// IsJSON reports whether data looks like a JSON object, i.e. a JWK or a
+// JWK Set rather than PEM or a raw secret.
+func IsJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// IsJWK reports whether data is a JSON object with a "kty" or "keys"
+// member. Unlike IsJSON it tells a JWK or JWK Set apart from a raw secret
+// that merely starts with "{".
+func IsJWK(data []byte) bool {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, kty := probe["kty"]
+	_, keys := probe["keys"]
+	return kty || keys
+}
+
+// ParseSet parses a JWK Set, or a single JWK which is returned as a Set
+// with one key.
+func ParseSet(data []byte) (*Set, error) {
+	var probe struct {
+		Keys json.RawMessage `json:"keys"`
+		Kty  string          `json:"kty"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parse JWK: %w", err)
+	}
+	if probe.Keys != nil {
+		var set Set
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("parse JWK Set: %w", err)
+		}
+		return &set, nil
+	}
+	if probe.Kty == "" {
+		return nil, fmt.Errorf(`parse JWK: missing "kty" or "keys" member`)
+	}
+	var k Key
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("parse JWK: %w", err)
+	}
+	return &Set{Keys: []Key{k}}, nil
+}
+
+// Lookup returns the key with the given kid.
+func (s *Set) Lookup(kid string) (*Key, error) {
+	for i := range s.Keys {
+		if s.Keys[i].Kid == kid {
+			return &s.Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrNoKey, kid)
+}
+
+// Usable reports whether k can be used with the algorithm alg for keys of
+// type kty on the curve crv (any curve when empty) and the use "sig" or
+// "enc". Absent "alg" and "use" members do not restrict the key.
+func (k *Key) Usable(kty, crv, alg, use string) bool {
+	return k.Kty == kty && (crv == "" || k.Crv == crv) &&
+		(k.Alg == "" || k.Alg == alg) && (k.Use == "" || k.Use == use)
+}
+
+// Select returns the keys of s usable with alg, see Usable. When kid
+// names some of them, only those are returned.
+func (s *Set) Select(kty, crv, alg, use, kid string) []*Key {
+	var out, byKid []*Key
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if !k.Usable(kty, crv, alg, use) {
+			continue
+		}
+		out = append(out, k)
+		if kid != "" && k.Kid == kid {
+			byKid = append(byKid, k)
+		}
+	}
+	if len(byKid) > 0 {
+		return byKid
+	}
+	return out
+}
+
+// SelectOne returns the one key of s to use with alg: the key named by
+// kid when it is set, otherwise the only usable key, see Select.
+func (s *Set) SelectOne(kty, alg, use, kid string) (*Key, error) {
+	matches := s.Select(kty, "", alg, use, kid)
+	if kid != "" {
+		if len(matches) != 1 || matches[0].Kid != kid {
+			return nil, fmt.Errorf("%w: no single key with kid %q usable with %s", ErrNoKey, kid, alg)
+		}
+		return matches[0], nil
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("%w: %d %s keys usable with %s, select one by kid", ErrNoKey, len(matches), kty, alg)
+	}
+	return matches[0], nil
+}
+
+// decodeInt decodes a base64url-encoded unsigned big-endian integer member.
+func decodeInt(name, v string) (*big.Int, error) {
+	if v == "" {
+		return nil, fmt.Errorf("JWK member %q is missing", name)
+	}
+	b, err := b64.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("JWK member %q: %w", name, err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// curve returns the elliptic curve for a JWK "crv" value.
+func curve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	case "secp256k1":
+		return secp256k1.S256(), nil
+	}
+	return nil, fmt.Errorf("unsupported EC curve %q", crv)
+}
+
+// PublicKey returns the public key of k: *rsa.PublicKey, *ecdsa.PublicKey,
+// ed25519.PublicKey, or the secret []byte of an "oct" key.
+func (k *Key) PublicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA public exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		c, err := curve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeInt("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !c.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := b64.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf(`invalid "oct" key member "k"`)
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// PrivateKey returns the private key of k: *rsa.PrivateKey,
+// *ecdsa.PrivateKey, ed25519.PrivateKey, or the secret []byte of an "oct"
+// key. The private members must match the public ones.
+func (k *Key) PrivateKey() (any, error) {
+	if k.Kty == "oct" {
+		return k.PublicKey()
+	}
+	if k.D == "" {
+		return nil, ErrNotPrivate
+	}
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		d, err := decodeInt("d", k.D)
+		if err != nil {
+			return nil, err
+		}
+		var p, q *big.Int
+		if k.P == "" && k.Q == "" {
+			// RFC 7518 section 6.3.2 only requires "d".
+			if p, q, err = rsaPrimes(pub.N, pub.E, d); err != nil {
+				return nil, err
+			}
+		} else {
+			if p, err = decodeInt("p", k.P); err != nil {
+				return nil, err
+			}
+			if q, err = decodeInt("q", k.Q); err != nil {
+				return nil, err
+			}
+		}
+		priv := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+		if err := priv.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		priv.Precompute()
+		return priv, nil
+	case *ecdsa.PublicKey:
+		d, err := decodeInt("d", k.D)
+		if err != nil {
+			return nil, err
+		}
+		x, y := pub.Curve.ScalarBaseMult(d.Bytes())
+		if d.Sign() == 0 || d.Cmp(pub.Curve.Params().N) >= 0 || x.Cmp(pub.X) != 0 || y.Cmp(pub.Y) != 0 {
+			return nil, fmt.Errorf("EC private key does not match public key")
+		}
+		return &ecdsa.PrivateKey{PublicKey: *pub, D: d}, nil
+	case ed25519.PublicKey:
+		seed, err := b64.DecodeString(k.D)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid Ed25519 private key")
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		if !pub.Equal(priv.Public()) {
+			return nil, fmt.Errorf("Ed25519 private key does not match public key")
+		}
+		return priv, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// rsaPrimes recovers the prime factors of n from the exponents e and d,
+// following NIST SP 800-56B Appendix C: d*e - 1 is a multiple of the
+// order of every element, so repeatedly squaring g^r for its odd part r
+// reaches a non-trivial square root x of 1 for most g, and gcd(x-1, n) is
+// a factor.
+func rsaPrimes(n *big.Int, e int, d *big.Int) (*big.Int, *big.Int, error) {
+	one := big.NewInt(1)
+	k := new(big.Int).Mul(d, big.NewInt(int64(e)))
+	k.Sub(k, one)
+	if k.Sign() <= 0 || k.Bit(0) != 0 {
+		return nil, nil, fmt.Errorf("invalid RSA private key: d does not match e")
+	}
+	t := k.TrailingZeroBits()
+	r := new(big.Int).Rsh(k, t)
+	nMinusOne := new(big.Int).Sub(n, one)
+	for g := int64(2); g <= 64; g++ {
+		x := new(big.Int).Exp(big.NewInt(g), r, n)
+		for i := uint(0); i < t && x.Cmp(one) != 0 && x.Cmp(nMinusOne) != 0; i++ {
+			y := new(big.Int).Mul(x, x)
+			y.Mod(y, n)
+			if y.Cmp(one) == 0 {
+				p := new(big.Int).GCD(nil, nil, x.Sub(x, one), n)
+				return p, new(big.Int).Div(n, p), nil
+			}
+			x = y
+		}
+	}
+	return nil, nil, fmt.Errorf("invalid RSA private key: cannot recover the primes from d")
+}
@@ -0,0 +1,226 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	k1Key, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey secp256k1: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey Ed25519: %v", err)
+	}
+
+	for _, key := range []any{rsaKey, ecKey, k1Key, edKey, []byte("secret")} {
+		k, err := FromPrivateKey(key)
+		if err != nil {
+			t.Fatalf("FromPrivateKey(%T) error: %v", key, err)
+		}
+		data, err := json.Marshal(k)
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		set, err := ParseSet(data)
+		if err != nil {
+			t.Fatalf("ParseSet(%s) error: %v", data, err)
+		}
+		got, err := set.Keys[0].PrivateKey()
+		if err != nil {
+			t.Fatalf("PrivateKey(%T) error: %v", key, err)
+		}
+		switch want := key.(type) {
+		case *rsa.PrivateKey:
+			if !want.Equal(got) {
+				t.Fatalf("RSA key mismatch")
+			}
+		case *ecdsa.PrivateKey:
+			g := got.(*ecdsa.PrivateKey)
+			if g.D.Cmp(want.D) != 0 || g.X.Cmp(want.X) != 0 || g.Curve != want.Curve {
+				t.Fatalf("EC key mismatch")
+			}
+		default:
+			if !reflect.DeepEqual(got, key) {
+				t.Fatalf("%T key mismatch", key)
+			}
+		}
+	}
+}
+
+func TestParseSetLookup(t *testing.T) {
+	data := []byte(`{"keys":[{"kty":"oct","kid":"a","k":"YQ"},{"kty":"oct","kid":"b","k":"Yg"}]}`)
+	set, err := ParseSet(data)
+	if err != nil {
+		t.Fatalf("ParseSet error: %v", err)
+	}
+	k, err := set.Lookup("b")
+	if err != nil {
+		t.Fatalf("Lookup error: %v", err)
+	}
+	secret, err := k.PrivateKey()
+	if err != nil || string(secret.([]byte)) != "b" {
+		t.Fatalf("unexpected secret %v (%v)", secret, err)
+	}
+	if _, err := set.Lookup("c"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+	if !IsJSON([]byte("  {}")) || IsJSON([]byte("-----BEGIN")) {
+		t.Fatalf("IsJSON misdetects input")
+	}
+	if !IsJWK([]byte(` {"kty":"oct"} `)) || !IsJWK([]byte(`{"keys":[]}`)) ||
+		IsJWK([]byte(`{my secret}`)) || IsJWK([]byte(`{"a":1}`)) {
+		t.Fatalf("IsJWK misdetects input")
+	}
+}
+
+func TestRSAPrivateKeyWithoutPrimes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	k, err := FromPrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("FromPrivateKey error: %v", err)
+	}
+	k.P, k.Q, k.Dp, k.Dq, k.Qi = "", "", "", "", ""
+
+	priv, err := k.PrivateKey()
+	if err != nil {
+		t.Fatalf("PrivateKey error: %v", err)
+	}
+	got := priv.(*rsa.PrivateKey)
+	if !got.PublicKey.Equal(&rsaKey.PublicKey) || got.D.Cmp(rsaKey.D) != 0 ||
+		new(big.Int).Mul(got.Primes[0], got.Primes[1]).Cmp(rsaKey.N) != 0 {
+		t.Fatalf("recovered key does not match the original")
+	}
+	digest := sha256.Sum256([]byte("payload"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, got, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("VerifyPKCS1v15: %v", err)
+	}
+
+	k.D = b64.EncodeToString(new(big.Int).Add(rsaKey.D, big.NewInt(2)).Bytes())
+	if _, err := k.PrivateKey(); err == nil {
+		t.Fatalf("expected error for a d that does not match n and e")
+	}
+}
+
+func TestSetSelect(t *testing.T) {
+	set, err := ParseSet([]byte(`{"keys":[
+		{"kty":"EC","kid":"p256","crv":"P-256","use":"sig"},
+		{"kty":"EC","kid":"k1","crv":"secp256k1","alg":"ES256K"},
+		{"kty":"EC","kid":"enc","crv":"P-256","use":"enc"},
+		{"kty":"oct","kid":"hs","alg":"HS256"},
+		{"kty":"oct","kid":"hs2"}]}`))
+	if err != nil {
+		t.Fatalf("ParseSet error: %v", err)
+	}
+	kids := func(keys []*Key) (out []string) {
+		for _, k := range keys {
+			out = append(out, k.Kid)
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		kty, crv, alg, use, kid string
+		want                    []string
+	}{
+		{"EC", "", "ES256", "sig", "", []string{"p256"}},
+		{"EC", "secp256k1", "ES256K", "sig", "", []string{"k1"}},
+		{"EC", "", "ECDH-ES", "enc", "", []string{"enc"}},
+		{"oct", "", "HS256", "sig", "", []string{"hs", "hs2"}},
+		{"oct", "", "HS256", "sig", "hs2", []string{"hs2"}},
+		{"oct", "", "HS256", "sig", "other", []string{"hs", "hs2"}},
+		{"oct", "", "HS512", "sig", "hs", []string{"hs2"}},
+		{"RSA", "", "RS256", "sig", "", nil},
+	} {
+		if got := kids(set.Select(tc.kty, tc.crv, tc.alg, tc.use, tc.kid)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Select(%q, %q, %q, %q, %q) = %v, want %v", tc.kty, tc.crv, tc.alg, tc.use, tc.kid, got, tc.want)
+		}
+	}
+
+	if k, err := set.SelectOne("EC", "ECDH-ES", "enc", ""); err != nil || k.Kid != "enc" {
+		t.Fatalf("SelectOne: %v, %v", k, err)
+	}
+	if k, err := set.SelectOne("oct", "HS256", "sig", "hs"); err != nil || k.Kid != "hs" {
+		t.Fatalf("SelectOne by kid: %v, %v", k, err)
+	}
+	if _, err := set.SelectOne("oct", "HS256", "sig", ""); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for two usable keys, got %v", err)
+	}
+	if _, err := set.SelectOne("oct", "HS512", "sig", "hs"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for a kid usable with another alg, got %v", err)
+	}
+	if _, err := set.SelectOne("oct", "HS256", "sig", "other"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for an unknown kid, got %v", err)
+	}
+}
+
+func TestPrivateKeyErrors(t *testing.T) {
+	if _, err := ParseSet([]byte(`{"a":1}`)); err == nil {
+		t.Fatalf("expected error for JSON without kty or keys")
+	}
+	if _, err := ParseSet([]byte(`nope`)); err == nil {
+		t.Fatalf("expected error for invalid JSON")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	pub, err := FromPublicKey(ecKey)
+	if err != nil {
+		t.Fatalf("FromPublicKey error: %v", err)
+	}
+	if _, err := pub.PrivateKey(); !errors.Is(err, ErrNotPrivate) {
+		t.Fatalf("expected ErrNotPrivate, got %v", err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	mismatch, err := FromPrivateKey(other)
+	if err != nil {
+		t.Fatalf("FromPrivateKey error: %v", err)
+	}
+	mismatch.X = pub.X
+	if _, err := mismatch.PrivateKey(); err == nil {
+		t.Fatalf("expected error for mismatched EC key")
+	}
+
+	bad := &Key{Kty: "EC", Crv: "P-256", X: pub.X, Y: pub.X}
+	if _, err := bad.PublicKey(); err == nil {
+		t.Fatalf("expected error for point not on curve")
+	}
+	if _, err := (&Key{Kty: "OKP", Crv: "X25519", X: "AA"}).PublicKey(); err == nil {
+		t.Fatalf("expected error for X25519")
+	}
+}
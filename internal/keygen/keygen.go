@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: MIT
+
+// Package keygen generates signing keys and encodes them in the formats
+// accepted by the sign package: PKCS1 PEM for RSA, SEC 1 PEM for EC,
+// PKCS8 PEM for Ed25519 and a text secret for HMAC.
+package keygen
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Key types accepted by Generate.
+const (
+	TypeRSA     = "rsa"
+	TypeEC      = "ec"
+	TypeEd25519 = "ed25519"
+	TypeHMAC    = "hmac"
+)
+
+var ErrUnknownType = errors.New("unknown key type")
+
+// Generate creates a new key of the given type:
+//
+//   - rsa: *rsa.PrivateKey with bits of 2048, 3072 or 4096;
+//   - ec: *ecdsa.PrivateKey on curve P-256, P-384 or P-521;
+//   - ed25519: ed25519.PrivateKey;
+//   - hmac: []byte text secret with bits of 256, 384 or 512: that many
+//     random bits, base64url-encoded without padding.
+//
+// bits is ignored for ec and ed25519, curve is only used for ec.
+func Generate(typ string, bits int, curve string) (any, error) {
+	switch typ {
+	case TypeRSA:
+		if bits != 2048 && bits != 3072 && bits != 4096 {
+			return nil, fmt.Errorf("RSA key size must be 2048, 3072 or 4096 bits, got %d", bits)
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case TypeEC:
+		var c elliptic.Curve
+		switch curve {
+		case "P-256":
+			c = elliptic.P256()
+		case "P-384":
+			c = elliptic.P384()
+		case "P-521":
+			c = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("EC curve must be P-256, P-384 or P-521, got %q", curve)
+		}
+		return ecdsa.GenerateKey(c, rand.Reader)
+	case TypeEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case TypeHMAC:
+		if bits != 256 && bits != 384 && bits != 512 {
+			return nil, fmt.Errorf("HMAC secret size must be 256, 384 or 512 bits, got %d", bits)
+		}
+		random := make([]byte, bits/8)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		// Each character carries 6 of the random bits, so the text secret
+		// is longer than bits/8 but holds exactly bits of entropy.
+		return base64.RawURLEncoding.AppendEncode(nil, random), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownType, typ)
+}
+
+// MarshalPrivate encodes a key returned by Generate. HMAC secrets are
+// returned as is, without a trailing newline, since signers use the whole
+// key file as the secret.
+func MarshalPrivate(key any) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	case []byte:
+		return append([]byte(nil), k...), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// MarshalPublic encodes the public part of an asymmetric key returned by
+// Generate as PKIX PEM.
+func MarshalPublic(key any) ([]byte, error) {
+	var pub any
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		pub = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		pub = &k.PublicKey
+	case ed25519.PrivateKey:
+		pub = k.Public()
+	default:
+		return nil, fmt.Errorf("key type %T has no public part", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
@@ -0,0 +1,107 @@
+package keygen
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+func TestGenerateRoundTripsThroughSigners(t *testing.T) {
+	cases := []struct {
+		typ   string
+		bits  int
+		curve string
+		alg   string
+	}{
+		{TypeRSA, 2048, "", "RS256"},
+		{TypeEC, 0, "P-256", "ES256"},
+		{TypeEC, 0, "P-384", "ES384"},
+		{TypeEC, 0, "P-521", "ES512"},
+		{TypeEd25519, 0, "", "EdDSA"},
+		{TypeHMAC, 256, "", "HS256"},
+		{TypeHMAC, 512, "", "HS512"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.alg, func(t *testing.T) {
+			key, err := Generate(tc.typ, tc.bits, tc.curve)
+			if err != nil {
+				t.Fatalf("Generate error: %v", err)
+			}
+			data, err := MarshalPrivate(key)
+			if err != nil {
+				t.Fatalf("MarshalPrivate error: %v", err)
+			}
+			s, err := sign.ParseSigner(tc.alg, data)
+			if err != nil {
+				t.Fatalf("ParseSigner error: %v", err)
+			}
+			if _, err := s.Sign([]byte(`{}`)); err != nil {
+				t.Fatalf("Sign error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateHMACSecret(t *testing.T) {
+	key, err := Generate(TypeHMAC, 384, "")
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	secret := key.([]byte)
+	if len(secret) != 64 {
+		t.Fatalf("expected 64-character secret, got %d", len(secret))
+	}
+	// All 384 random bits are kept: the secret decodes to 48 bytes.
+	random, err := base64.RawURLEncoding.DecodeString(string(secret))
+	if err != nil || len(random) != 48 {
+		t.Fatalf("expected base64url of 48 random bytes, got %d bytes (%v)", len(random), err)
+	}
+	if _, err := MarshalPublic(secret); err == nil {
+		t.Fatalf("expected error for public part of HMAC secret")
+	}
+}
+
+func TestMarshalPublic(t *testing.T) {
+	for _, typ := range []string{TypeRSA, TypeEC, TypeEd25519} {
+		key, err := Generate(typ, 2048, "P-256")
+		if err != nil {
+			t.Fatalf("Generate(%s) error: %v", typ, err)
+		}
+		switch key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		default:
+			t.Fatalf("unexpected key type %T", key)
+		}
+		data, err := MarshalPublic(key)
+		if err != nil {
+			t.Fatalf("MarshalPublic(%s) error: %v", typ, err)
+		}
+		if !strings.HasPrefix(string(data), "-----BEGIN PUBLIC KEY-----") {
+			t.Fatalf("unexpected public key PEM: %s", data)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if _, err := Generate("dsa", 0, ""); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType, got %v", err)
+	}
+	if _, err := Generate(TypeRSA, 1024, ""); err == nil {
+		t.Fatalf("expected error for 1024-bit RSA")
+	}
+	if _, err := Generate(TypeEC, 0, "P-224"); err == nil {
+		t.Fatalf("expected error for P-224")
+	}
+	if _, err := Generate(TypeHMAC, 128, ""); err == nil {
+		t.Fatalf("expected error for 128-bit secret")
+	}
+	if _, err := MarshalPrivate("nope"); err == nil {
+		t.Fatalf("expected error for unsupported key")
+	}
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: MIT
+
+// Package keys loads PEM-encoded private and public keys for the sign and
+// encrypt packages. Private keys may be PKCS1, SEC 1 or PKCS8, optionally
+// passphrase-encrypted PKCS8; public keys may be PKIX or PKCS1.
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var (
+	ErrNoPEM        = errors.New("no PEM block found")
+	ErrNoPassphrase = errors.New("key is encrypted and no passphrase was given")
+	ErrPassphrase   = errors.New("wrong passphrase or corrupt encrypted key")
+)
+
+// PEM block types understood by ParsePrivateKey and ParsePublicKey.
+const (
+	typePKCS1Private     = "RSA PRIVATE KEY"
+	typeSEC1Private      = "EC PRIVATE KEY"
+	typePKCS8Private     = "PRIVATE KEY"
+	typeEncryptedPrivate = "ENCRYPTED PRIVATE KEY"
+	typePKIXPublic       = "PUBLIC KEY"
+	typePKCS1Public      = "RSA PUBLIC KEY"
+	typeECParameters     = "EC PARAMETERS"
+)
+
+// decode returns the first PEM block in data, skipping an "EC PARAMETERS"
+// block as written by openssl ecparam -genkey.
+func decode(data []byte) (*pem.Block, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("empty key")
+	}
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, ErrNoPEM
+		}
+		if block.Type != typeECParameters {
+			return block, nil
+		}
+	}
+}
+
+// ParsePrivateKey parses the first PEM private key in data and returns an
+// *rsa.PrivateKey, *ecdsa.PrivateKey (including secp256k1) or
+// ed25519.PrivateKey. An "ENCRYPTED PRIVATE KEY" block is decrypted with
+// passphrase; it is ignored for unencrypted keys.
+func ParsePrivateKey(data, passphrase []byte) (any, error) {
+	block, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := block.Headers["Proc-Type"]; ok {
+		return nil, fmt.Errorf("PEM block %q uses legacy OpenSSL encryption, which is insecure and not supported; "+
+			"convert it with: openssl pkcs8 -topk8 -v2 aes-256-cbc", block.Type)
+	}
+
+	switch block.Type {
+	case typePKCS1Private:
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: parse PKCS1 RSA private key: %w", block.Type, err)
+		}
+		return key, nil
+	case typeSEC1Private:
+		key, err := parseSEC1(block.Bytes, nil)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: parse SEC 1 EC private key: %w", block.Type, err)
+		}
+		return key, nil
+	case typePKCS8Private:
+		key, err := parsePKCS8(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: %w", block.Type, err)
+		}
+		return key, nil
+	case typeEncryptedPrivate:
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("PEM block %q: %w", block.Type, ErrNoPassphrase)
+		}
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: %w", block.Type, err)
+		}
+		key, err := parsePKCS8(der)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: %w: %w", block.Type, ErrPassphrase, err)
+		}
+		return key, nil
+	case typePKIXPublic, typePKCS1Public:
+		return nil, fmt.Errorf("PEM block %q holds a public key, a private key is required", block.Type)
+	case "OPENSSH PRIVATE KEY":
+		return nil, fmt.Errorf("PEM block %q is in OpenSSH format, which is not supported; "+
+			"convert it with: ssh-keygen -p -m PKCS8 -f <file>", block.Type)
+	default:
+		return nil, fmt.Errorf("PEM block %q is not a private key; expected %q, %q, %q or %q",
+			block.Type, typePKCS1Private, typeSEC1Private, typePKCS8Private, typeEncryptedPrivate)
+	}
+}
+
+// ParsePublicKey parses the first PEM public key in data and returns an
+// *rsa.PublicKey, *ecdsa.PublicKey (including secp256k1) or
+// ed25519.PublicKey.
+func ParsePublicKey(data []byte) (any, error) {
+	block, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case typePKIXPublic:
+		key, err := parsePKIX(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: %w", block.Type, err)
+		}
+		return key, nil
+	case typePKCS1Public:
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("PEM block %q: parse PKCS1 RSA public key: %w", block.Type, err)
+		}
+		return key, nil
+	case typePKCS1Private, typeSEC1Private, typePKCS8Private, typeEncryptedPrivate:
+		return nil, fmt.Errorf("PEM block %q holds a private key, a public key is expected; "+
+			"extract it with: openssl pkey -pubout", block.Type)
+	case "CERTIFICATE":
+		return nil, fmt.Errorf("PEM block %q is a certificate, not a bare public key; "+
+			"extract it with: openssl x509 -pubkey -noout", block.Type)
+	default:
+		return nil, fmt.Errorf("PEM block %q is not a public key; expected %q or %q",
+			block.Type, typePKIXPublic, typePKCS1Public)
+	}
+}
+
+// oidSecp256k1 is the SEC 2 object identifier of the secp256k1 curve.
+var oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+// oidPublicKeyECDSA is the algorithm identifier of EC keys in PKCS8 and
+// PKIX structures.
+var oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+// sec1PrivateKey is the SEC 1 ECPrivateKey structure (RFC 5915).
+type sec1PrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// pkcs8PrivateKey is the PKCS8 PrivateKeyInfo structure (RFC 5208).
+type pkcs8PrivateKey struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// subjectPublicKeyInfo is the PKIX SubjectPublicKeyInfo structure
+// (RFC 5280).
+type subjectPublicKeyInfo struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ecCurve returns the named curve OID from EC algorithm parameters.
+func ecCurve(algo pkix.AlgorithmIdentifier) (asn1.ObjectIdentifier, bool) {
+	var curve asn1.ObjectIdentifier
+	if !algo.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, false
+	}
+	if _, err := asn1.Unmarshal(algo.Parameters.FullBytes, &curve); err != nil {
+		return nil, false
+	}
+	return curve, true
+}
+
+// parseSEC1 parses a SEC 1 EC private key. crypto/x509 does not know
+// secp256k1, so keys on that curve are decoded here; curveOID carries the
+// curve from an enclosing PKCS8 structure.
+func parseSEC1(der []byte, curveOID asn1.ObjectIdentifier) (any, error) {
+	var key sec1PrivateKey
+	if _, err := asn1.Unmarshal(der, &key); err != nil {
+		return nil, err
+	}
+	if len(key.NamedCurveOID) > 0 {
+		curveOID = key.NamedCurveOID
+	}
+	if !curveOID.Equal(oidSecp256k1) {
+		return x509.ParseECPrivateKey(der)
+	}
+
+	curve := secp256k1.S256()
+	d := new(big.Int).SetBytes(key.PrivateKey)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid secp256k1 private key scalar")
+	}
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.Curve = curve
+	priv.X, priv.Y = curve.ScalarBaseMult(key.PrivateKey)
+	return priv, nil
+}
+
+// parsePKCS8 parses an unencrypted PKCS8 private key.
+func parsePKCS8(der []byte) (any, error) {
+	var p8 pkcs8PrivateKey
+	if _, err := asn1.Unmarshal(der, &p8); err != nil {
+		return nil, fmt.Errorf("parse PKCS8 private key: %w", err)
+	}
+	if curve, ok := ecCurve(p8.Algo); ok && curve.Equal(oidSecp256k1) {
+		key, err := parseSEC1(p8.PrivateKey, curve)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS8 secp256k1 private key: %w", err)
+		}
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS8 private key: %w", err)
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("PKCS8 key of type %T is not supported", key)
+	}
+}
+
+// parsePKIX parses a PKIX public key.
+func parsePKIX(der []byte) (any, error) {
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("parse PKIX public key: %w", err)
+	}
+	if curve, ok := ecCurve(spki.Algo); ok && curve.Equal(oidSecp256k1) {
+		pub, err := secp256k1.ParsePubKey(spki.PublicKey.RightAlign())
+		if err != nil {
+			return nil, fmt.Errorf("parse PKIX secp256k1 public key: %w", err)
+		}
+		return pub.ToECDSA(), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKIX public key: %w", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("PKIX key of type %T is not supported", key)
+	}
+}
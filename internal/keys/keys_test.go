@@ -0,0 +1,208 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func pemBlock(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func TestParsePrivateKeyFormats(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey RSA: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey Ed25519: %v", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+	pkcs8 := func(key any) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+		}
+		return der
+	}
+	ecParams := pemBlock("EC PARAMETERS", mustMarshal(t, asn1.ObjectIdentifier{1, 3, 132, 0, 34}))
+
+	cases := []struct {
+		name string
+		data []byte
+		want crypto.PrivateKey
+	}{
+		{"PKCS1 RSA", pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), rsaKey},
+		{"PKCS8 RSA", pemBlock("PRIVATE KEY", pkcs8(rsaKey)), rsaKey},
+		{"SEC1 EC", pemBlock("EC PRIVATE KEY", sec1), ecKey},
+		{"SEC1 EC with parameters", append(ecParams, pemBlock("EC PRIVATE KEY", sec1)...), ecKey},
+		{"PKCS8 EC", pemBlock("PRIVATE KEY", pkcs8(ecKey)), ecKey},
+		{"PKCS8 Ed25519", pemBlock("PRIVATE KEY", pkcs8(edKey)), edKey},
+	}
+	for _, tc := range cases {
+		key, err := ParsePrivateKey(tc.data, nil)
+		if err != nil {
+			t.Fatalf("%s: ParsePrivateKey: %v", tc.name, err)
+		}
+		if !key.(interface{ Equal(crypto.PrivateKey) bool }).Equal(tc.want) {
+			t.Fatalf("%s: parsed key differs", tc.name)
+		}
+	}
+}
+
+func TestParseSecp256k1(t *testing.T) {
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey secp256k1: %v", err)
+	}
+	want := priv.ToECDSA()
+	sec1 := mustMarshal(t, sec1PrivateKey{Version: 1, PrivateKey: priv.Serialize(), NamedCurveOID: oidSecp256k1})
+	algo := pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: mustMarshal(t, oidSecp256k1)}}
+	pkcs8 := mustMarshal(t, pkcs8PrivateKey{
+		Algo:       algo,
+		PrivateKey: mustMarshal(t, sec1PrivateKey{Version: 1, PrivateKey: priv.Serialize()}),
+	})
+
+	for name, data := range map[string][]byte{
+		"SEC1":  pemBlock("EC PRIVATE KEY", sec1),
+		"PKCS8": pemBlock("PRIVATE KEY", pkcs8),
+	} {
+		key, err := ParsePrivateKey(data, nil)
+		if err != nil {
+			t.Fatalf("%s: ParsePrivateKey: %v", name, err)
+		}
+		got := key.(*ecdsa.PrivateKey)
+		if got.D.Cmp(want.D) != 0 || got.X.Cmp(want.X) != 0 || got.Curve.Params().Name != "secp256k1" {
+			t.Fatalf("%s: parsed key differs", name)
+		}
+	}
+
+	spki := mustMarshal(t, subjectPublicKeyInfo{
+		Algo:      algo,
+		PublicKey: asn1.BitString{Bytes: priv.PubKey().SerializeUncompressed(), BitLength: 65 * 8},
+	})
+	key, err := ParsePublicKey(pemBlock("PUBLIC KEY", spki))
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	if pub := key.(*ecdsa.PublicKey); pub.X.Cmp(want.X) != 0 || pub.Y.Cmp(want.Y) != 0 {
+		t.Fatalf("parsed public key differs")
+	}
+
+	zero := mustMarshal(t, sec1PrivateKey{Version: 1, PrivateKey: make([]byte, 32), NamedCurveOID: oidSecp256k1})
+	if _, err := ParsePrivateKey(pemBlock("EC PRIVATE KEY", zero), nil); err == nil {
+		t.Fatalf("expected error for zero scalar")
+	}
+}
+
+func TestParsePublicKeyFormats(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey RSA: %v", err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey Ed25519: %v", err)
+	}
+	pkix := func(key any) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("MarshalPKIXPublicKey: %v", err)
+		}
+		return der
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+		want crypto.PublicKey
+	}{
+		{"PKIX RSA", pemBlock("PUBLIC KEY", pkix(&rsaKey.PublicKey)), &rsaKey.PublicKey},
+		{"PKCS1 RSA", pemBlock("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), &rsaKey.PublicKey},
+		{"PKIX Ed25519", pemBlock("PUBLIC KEY", pkix(edPub)), edPub},
+	}
+	for _, tc := range cases {
+		key, err := ParsePublicKey(tc.data)
+		if err != nil {
+			t.Fatalf("%s: ParsePublicKey: %v", tc.name, err)
+		}
+		if !key.(interface{ Equal(crypto.PublicKey) bool }).Equal(tc.want) {
+			t.Fatalf("%s: parsed key differs", tc.name)
+		}
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey RSA: %v", err)
+	}
+	pkcs1 := x509.MarshalPKCS1PrivateKey(rsaKey)
+	legacy := pem.EncodeToMemory(&pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-256-CBC,00"},
+		Bytes:   pkcs1,
+	})
+
+	private := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "empty key"},
+		{"not PEM", []byte("secret"), ErrNoPEM.Error()},
+		{"public key", pemBlock("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), `"RSA PUBLIC KEY" holds a public key`},
+		{"certificate", pemBlock("CERTIFICATE", []byte{0}), `"CERTIFICATE" is not a private key`},
+		{"legacy encryption", legacy, `"RSA PRIVATE KEY" uses legacy OpenSSL encryption`},
+		{"openssh", pemBlock("OPENSSH PRIVATE KEY", []byte{0}), "ssh-keygen -p -m PKCS8"},
+		{"corrupt PKCS1", pemBlock("RSA PRIVATE KEY", []byte{1, 2, 3}), `"RSA PRIVATE KEY": parse PKCS1`},
+		{"SEC1 in PKCS8 block", pemBlock("PRIVATE KEY", pkcs1), `"PRIVATE KEY": parse PKCS8`},
+	}
+	for _, tc := range private {
+		_, err := ParsePrivateKey(tc.data, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+	if _, err := ParsePrivateKey([]byte("secret"), nil); !errors.Is(err, ErrNoPEM) {
+		t.Fatalf("expected ErrNoPEM, got %v", err)
+	}
+
+	public := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"private key", pemBlock("RSA PRIVATE KEY", pkcs1), `"RSA PRIVATE KEY" holds a private key`},
+		{"certificate", pemBlock("CERTIFICATE", []byte{0}), "openssl x509 -pubkey"},
+		{"unknown", pemBlock("DH PARAMETERS", []byte{0}), `"DH PARAMETERS" is not a public key`},
+		{"corrupt PKIX", pemBlock("PUBLIC KEY", []byte{1, 2, 3}), `"PUBLIC KEY": parse PKIX`},
+	}
+	for _, tc := range public {
+		_, err := ParsePublicKey(tc.data)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
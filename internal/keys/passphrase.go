@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: MIT
+
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ReadPassphrase returns the passphrase from the environment variable
+// named env or from file, whichever is set. Trailing line breaks in the
+// file are removed. With neither set it returns nil.
+func ReadPassphrase(env, file string) ([]byte, error) {
+	switch {
+	case env != "" && file != "":
+		return nil, errors.New("passphrase may come from an environment variable or a file, not both")
+	case env != "":
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("passphrase environment variable %s is not set", env)
+		}
+		return []byte(v), nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read passphrase file: %w", err)
+		}
+		return bytes.TrimRight(data, "\r\n"), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,37 @@
+package keys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPassphrase(t *testing.T) {
+	t.Setenv("JWT_TEST_KEY_PASS", "from-env")
+	got, err := ReadPassphrase("JWT_TEST_KEY_PASS", "")
+	if err != nil || string(got) != "from-env" {
+		t.Fatalf("env: got %q, %v", got, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pass.txt")
+	if err := os.WriteFile(path, []byte("from file \r\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err = ReadPassphrase("", path)
+	if err != nil || string(got) != "from file " {
+		t.Fatalf("file: got %q, %v", got, err)
+	}
+
+	if got, err := ReadPassphrase("", ""); err != nil || got != nil {
+		t.Fatalf("none: got %q, %v", got, err)
+	}
+	if _, err := ReadPassphrase("JWT_TEST_KEY_PASS", path); err == nil {
+		t.Fatalf("expected error for both sources")
+	}
+	if _, err := ReadPassphrase("JWT_TEST_KEY_PASS_UNSET", ""); err == nil {
+		t.Fatalf("expected error for unset variable")
+	}
+	if _, err := ReadPassphrase("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: MIT
+
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+)
+
+// Object identifiers of PKCS #5 v2 (RFC 8018) encryption schemes.
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// encryptedPrivateKeyInfo is the PKCS8 EncryptedPrivateKeyInfo structure
+// (RFC 5208).
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params is the PBES2-params structure (RFC 8018, appendix A.4).
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params is the PBKDF2-params structure (RFC 8018, appendix A.2).
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// prfHash returns the hash of a PBKDF2 pseudo-random function. An absent
+// PRF means hmacWithSHA1.
+func prfHash(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		return sha1.New, nil
+	case oid.Equal(oidHMACWithSHA224):
+		return sha256.New224, nil
+	case oid.Equal(oidHMACWithSHA256):
+		return sha256.New, nil
+	case oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, nil
+	case oid.Equal(oidHMACWithSHA512):
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %v", oid)
+	}
+}
+
+// blockCipher returns the key size and constructor of a PBES2 encryption
+// scheme.
+func blockCipher(oid asn1.ObjectIdentifier) (int, func([]byte) (cipher.Block, error), error) {
+	switch {
+	case oid.Equal(oidAES128CBC):
+		return 16, aes.NewCipher, nil
+	case oid.Equal(oidAES192CBC):
+		return 24, aes.NewCipher, nil
+	case oid.Equal(oidAES256CBC):
+		return 32, aes.NewCipher, nil
+	case oid.Equal(oidDESEDE3CBC):
+		return 24, des.NewTripleDESCipher, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported PBES2 encryption scheme %v", oid)
+	}
+}
+
+// decryptPKCS8 decrypts a PBES2-encrypted PKCS8 private key with PBKDF2
+// key derivation and returns the PrivateKeyInfo DER. The legacy PBES1
+// schemes are rejected.
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("parse encrypted PKCS8 private key: %w", err)
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("encryption algorithm %v is not supported, only PBES2; "+
+			"re-encrypt with: openssl pkcs8 -topk8 -v2 aes-256-cbc", info.Algo.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("parse PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("key derivation function %v is not supported, only PBKDF2", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("parse PBKDF2 parameters: %w", err)
+	}
+	h, err := prfHash(kdf.PRF.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	keyLen, newCipher, err := blockCipher(params.EncryptionScheme.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, fmt.Errorf("PBKDF2 key length %d does not match the cipher's %d", kdf.KeyLength, keyLen)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("parse cipher IV: %w", err)
+	}
+
+	key, err := pbkdf2.Key(h, string(passphrase), kdf.Salt, kdf.IterationCount, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, ErrPassphrase
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	// PKCS #5 padding; a wrong passphrase usually fails here.
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, ErrPassphrase
+	}
+	for _, b := range out[len(out)-pad:] {
+		if int(b) != pad {
+			return nil, ErrPassphrase
+		}
+	}
+	return out[:len(out)-pad], nil
+}
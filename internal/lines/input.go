@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: MIT
+
+package lines
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Input formats, see Config.Format.
+const (
+	// FormatLines takes one payload per line.
+	FormatLines = "lines"
+	// FormatJSONArray takes the elements of a JSON array as payloads.
+	FormatJSONArray = "json-array"
+	// FormatJSONLEnvelope takes one Envelope per line.
+	FormatJSONLEnvelope = "jsonl-envelope"
+	// FormatCSV takes CSV rows as claim sets. The first row names the
+	// claims, see csvSource.
+	FormatCSV = "csv"
+)
+
+// Formats lists the accepted values of Config.Format.
+var Formats = []string{FormatLines, FormatJSONArray, FormatJSONLEnvelope, FormatCSV}
+
+// Envelope is a jsonl-envelope input line: a payload with protected
+// header parameters for its token only.
+type Envelope struct {
+	Header  map[string]any  `json:"header,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// record is one payload read from the input. n is the input line, or the
+// element number for FormatJSONArray. err is set instead of data when the
+// record could not be read but the following ones can.
+type record struct {
+	n    int
+	data []byte
+	err  error
+	// idle, when positive, makes the record a marker without payload: the
+	// input was used up after this many records, see readAhead.
+	idle int
+}
+
+// source yields the records of the input, and io.EOF after the last one.
+// data is only valid until the next call.
+type source interface {
+	next() (record, error)
+}
+
+// newSource returns the source for cfg.Format.
+func newSource(r io.Reader, cfg Config) (source, error) {
+	switch cfg.Format {
+	case "", FormatLines, FormatJSONLEnvelope:
+		lr := NewReader(r, cfg.MaxLineBytes)
+		lr.KeepCR = cfg.Raw && cfg.KeepCR
+		return &lineSource{r: lr, raw: cfg.Raw && cfg.Format != FormatJSONLEnvelope}, nil
+	case FormatJSONArray:
+		return &arraySource{r: bufio.NewReaderSize(r, readBufferSize), max: maxLineBytes(cfg)}, nil
+	case FormatCSV:
+		return &csvSource{r: csv.NewReader(r), max: maxLineBytes(cfg)}, nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q", cfg.Format)
+	}
+}
+
+func maxLineBytes(cfg Config) int {
+	if cfg.MaxLineBytes <= 0 {
+		return DefaultMaxLineBytes
+	}
+	return cfg.MaxLineBytes
+}
+
+// lineSource yields input lines, trimmed and without empty ones unless
+// raw is set.
+type lineSource struct {
+	r   *Reader
+	raw bool
+}
+
+func (s *lineSource) next() (record, error) {
+	for {
+		line, err := s.r.Next()
+		var tooLong *LineTooLongError
+		if errors.As(err, &tooLong) {
+			return record{n: s.r.Line(), err: err}, nil
+		}
+		if err != nil {
+			return record{}, err
+		}
+		if !s.raw {
+			if line = bytes.TrimSpace(line); len(line) == 0 {
+				continue
+			}
+		}
+		return record{n: s.r.Line(), data: line}, nil
+	}
+}
+
+// arraySource yields the elements of a JSON array, streamed so that the
+// array is never held in memory as a whole. Like Reader, it keeps at most
+// max bytes of an element: a longer one is skipped while counting its size
+// and reported as a record wrapping *LineTooLongError.
+type arraySource struct {
+	r       *bufio.Reader
+	max     int
+	n       int
+	started bool
+	buf     []byte
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+func (s *arraySource) next() (record, error) {
+	if !s.started {
+		s.started = true
+		if c, err := s.skipSpace(); err != nil || c != '[' {
+			if err == nil || err == io.EOF {
+				err = errors.New("expected a JSON array")
+			}
+			return record{}, fmt.Errorf("json-array: %w", err)
+		}
+	}
+	c, err := s.skipSpace()
+	if err == nil && c == ']' {
+		return record{}, io.EOF
+	}
+	if err == nil && s.n > 0 {
+		// Elements after the first follow a comma.
+		if c == ',' {
+			c, err = s.skipSpace()
+		} else {
+			err = fmt.Errorf("invalid character %q after array element", c)
+		}
+	}
+	s.n++
+	if err == io.EOF {
+		err = errUnexpectedEnd
+	}
+	if err != nil {
+		return record{}, fmt.Errorf("json-array: element %d: %w", s.n, err)
+	}
+	size, err := s.scanValue(c)
+	if err != nil {
+		return record{}, fmt.Errorf("json-array: element %d: %w", s.n, err)
+	}
+	if size > int64(s.max) {
+		return record{n: s.n, err: &LineTooLongError{Size: size, Max: s.max}}, nil
+	}
+	if !json.Valid(s.buf) {
+		err := json.Unmarshal(s.buf, new(json.RawMessage))
+		return record{}, fmt.Errorf("json-array: element %d: %w", s.n, err)
+	}
+	return record{n: s.n, data: s.buf}, nil
+}
+
+// skipSpace returns the next byte that is not JSON whitespace.
+func (s *arraySource) skipSpace() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil || (c != ' ' && c != '\t' && c != '\n' && c != '\r') {
+			return c, err
+		}
+	}
+}
+
+// scanValue reads the JSON value starting with c into s.buf, up to s.max
+// bytes, and returns its size. It only finds where the value ends, by
+// tracking strings and nesting; next checks the value itself.
+func (s *arraySource) scanValue(c byte) (int64, error) {
+	switch c {
+	case ',', ']', '}':
+		return 0, fmt.Errorf("invalid character %q looking for beginning of value", c)
+	}
+	var size int64
+	s.buf = s.buf[:0]
+	add := func(c byte) {
+		if size++; size <= int64(s.max) {
+			s.buf = append(s.buf, c)
+		}
+	}
+	add(c)
+	if c != '"' && c != '{' && c != '[' {
+		// A number or literal ends at the next delimiter.
+		for {
+			c, err := s.r.ReadByte()
+			if err == io.EOF {
+				return 0, errUnexpectedEnd
+			}
+			if err != nil {
+				return 0, err
+			}
+			switch c {
+			case ' ', '\t', '\n', '\r', ',', ']', '}':
+				return size, s.r.UnreadByte()
+			}
+			add(c)
+		}
+	}
+	inString, escaped, depth := c == '"', false, 0
+	if !inString {
+		depth = 1
+	}
+	for inString || depth > 0 {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return 0, errUnexpectedEnd
+		}
+		if err != nil {
+			return 0, err
+		}
+		add(c)
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			escaped = c == '\\'
+			inString = c != '"'
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return size, nil
+}
+
+// csvSource turns CSV rows into JSON claim sets. The first row holds the
+// claim names, each optionally followed by a type: "name:string" (the
+// default), "name:number", "name:bool" or "name:json" for a JSON value
+// such as an array. Empty cells leave the claim out.
+type csvSource struct {
+	r       *csv.Reader
+	max     int
+	columns []csvColumn
+}
+
+type csvColumn struct {
+	name []byte // JSON-encoded
+	typ  string
+}
+
+func (s *csvSource) next() (record, error) {
+	if s.columns == nil {
+		header, err := s.r.Read()
+		if err == io.EOF {
+			return record{}, io.EOF
+		}
+		if err != nil {
+			return record{}, fmt.Errorf("csv: %w", err)
+		}
+		if s.columns, err = csvColumns(header); err != nil {
+			return record{}, fmt.Errorf("csv: header: %w", err)
+		}
+	}
+	row, err := s.r.Read()
+	if err == io.EOF {
+		return record{}, io.EOF
+	}
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return record{}, fmt.Errorf("csv: %w", err)
+	}
+	n, _ := s.r.FieldPos(0)
+	if err != nil {
+		return record{n: n, err: fmt.Errorf("expected %d fields, got %d", len(s.columns), len(row))}, nil
+	}
+	data, err := s.claims(row)
+	if err != nil {
+		return record{n: n, err: err}, nil
+	}
+	if len(data) > s.max {
+		return record{n: n, err: &LineTooLongError{Size: int64(len(data)), Max: s.max}}, nil
+	}
+	return record{n: n, data: data}, nil
+}
+
+func csvColumns(header []string) ([]csvColumn, error) {
+	columns := make([]csvColumn, len(header))
+	for i, h := range header {
+		name, typ, _ := strings.Cut(strings.TrimSpace(h), ":")
+		switch typ {
+		case "":
+			typ = "string"
+		case "string", "number", "bool", "json":
+		default:
+			return nil, fmt.Errorf("column %q: unknown type %q, expected string, number, bool or json", name, typ)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("column %d has no name", i+1)
+		}
+		encoded, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = csvColumn{name: encoded, typ: typ}
+	}
+	return columns, nil
+}
+
+// claims returns row as a JSON object with the claims in column order.
+func (s *csvSource) claims(row []string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, cell := range row {
+		if cell == "" {
+			continue
+		}
+		c := s.columns[i]
+		var value []byte
+		switch c.typ {
+		case "string":
+			value, _ = json.Marshal(cell)
+		case "number":
+			if _, err := strconv.ParseFloat(cell, 64); err != nil || !json.Valid([]byte(cell)) {
+				return nil, fmt.Errorf("column %s: %q is not a number", c.name, cell)
+			}
+			value = []byte(cell)
+		case "bool":
+			v, err := strconv.ParseBool(cell)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %q is not a bool", c.name, cell)
+			}
+			value = strconv.AppendBool(nil, v)
+		case "json":
+			var v bytes.Buffer
+			if err := json.Compact(&v, []byte(cell)); err != nil {
+				return nil, fmt.Errorf("column %s: invalid JSON: %v", c.name, err)
+			}
+			value = v.Bytes()
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.Write(c.name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// payload returns the bytes to sign for a JSON value: the content of a
+// string, the compacted JSON of anything else.
+func payload(value json.RawMessage) ([]byte, error) {
+	if len(value) > 0 && value[0] == '"' {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, value); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// parseEnvelope returns the payload and header of a jsonl-envelope line.
+func parseEnvelope(line []byte) ([]byte, map[string]any, error) {
+	var env Envelope
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&env); err != nil {
+		return nil, nil, fmt.Errorf("envelope: %w", err)
+	}
+	if dec.More() {
+		return nil, nil, errors.New("envelope: data after the JSON object")
+	}
+	if len(env.Payload) == 0 || string(env.Payload) == "null" {
+		return nil, nil, errors.New("envelope: no payload")
+	}
+	data, err := payload(env.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("envelope: payload: %w", err)
+	}
+	return data, env.Header, nil
+}
@@ -0,0 +1,154 @@
+package lines
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe renders a record's line number, payload and header.
+func describe(n int, payload []byte, header map[string]any) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d %s %s", n, payload, h), nil
+}
+
+func TestProcessRecordsFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format, input, want string
+	}{
+		{
+			FormatJSONArray,
+			"[\n  {\"sub\": \"a\", \"n\": 1},\n  \"plain\",\n  [1, 2]\n]\n",
+			"1 {\"sub\":\"a\",\"n\":1} null\n2 plain null\n3 [1,2] null\n",
+		},
+		{FormatJSONArray, "[]", ""},
+		{
+			FormatJSONArray,
+			`[ "a]\"}", {"b": ["[", "{\\"]}, 12.5e3,null ]`,
+			"1 a]\"} null\n2 {\"b\":[\"[\",\"{\\\\\"]} null\n3 12.5e3 null\n4 null null\n",
+		},
+		{
+			FormatJSONLEnvelope,
+			`{"header":{"kid":"k1","typ":"at+jwt"},"payload":{"sub": "a"}}` + "\n\n" +
+				`{"payload":"text","header":{"n":12345678901234567890}}` + "\n",
+			"1 {\"sub\":\"a\"} {\"kid\":\"k1\",\"typ\":\"at+jwt\"}\n3 text {\"n\":12345678901234567890}\n",
+		},
+		{
+			FormatCSV,
+			"sub,exp:number,admin:bool,groups:json,note:string\n" +
+				"alice,1700000900,true,\"[\"\"a\"\", \"\"b\"\"]\",\n" +
+				"bob,,false,,\"x, y\"\n",
+			"2 {\"sub\":\"alice\",\"exp\":1700000900,\"admin\":true,\"groups\":[\"a\",\"b\"]} null\n" +
+				"3 {\"sub\":\"bob\",\"admin\":false,\"note\":\"x, y\"} null\n",
+		},
+		{FormatCSV, "", ""},
+	} {
+		for _, workers := range []int{1, 3} {
+			var got bytes.Buffer
+			cfg := Config{Format: tc.format, Workers: workers}
+			if err := ProcessRecords(strings.NewReader(tc.input), &got, cfg, describe); err != nil {
+				t.Fatalf("%s, %d workers: %v", tc.format, workers, err)
+			}
+			if got.String() != tc.want {
+				t.Fatalf("%s, %d workers: got %q, want %q", tc.format, workers, got.String(), tc.want)
+			}
+		}
+	}
+}
+
+func TestProcessRecordsFormatErrors(t *testing.T) {
+	// Record errors fail only their record with -on-error=emit.
+	for _, tc := range []struct {
+		format, input, want string
+	}{
+		{
+			FormatJSONLEnvelope,
+			"{\"payload\":{}}\n{\"header\":{}}\nnot json\n{\"payload\":1} x\n",
+			`1 {} null
+{"line":2,"error":"envelope: no payload"}
+{"line":3,"error":"envelope: invalid character 'o' in literal null (expecting 'u')"}
+{"line":4,"error":"envelope: data after the JSON object"}
+`,
+		},
+		{
+			FormatCSV,
+			"n:number,ok:bool\n1,true\nx,true\n1,maybe\n1\n2,false\n",
+			`2 {"n":1,"ok":true} null
+{"line":3,"error":"column \"n\": \"x\" is not a number"}
+{"line":4,"error":"column \"ok\": \"maybe\" is not a bool"}
+{"line":5,"error":"expected 2 fields, got 1"}
+6 {"n":2,"ok":false} null
+`,
+		},
+		{
+			FormatJSONArray,
+			`[{"big":"` + strings.Repeat("x", 100) + `"},{}]`,
+			`{"line":1,"error":"110 bytes exceeds the maximum line size of 100 bytes"}
+2 {} null
+`,
+		},
+		{
+			FormatJSONArray,
+			`[{"big":[` + strings.Repeat(`"]}\"",`, 40) + `1]},"x"]`,
+			`{"line":1,"error":"291 bytes exceeds the maximum line size of 100 bytes"}
+2 x null
+`,
+		},
+	} {
+		var got bytes.Buffer
+		cfg := Config{Format: tc.format, OnError: OnErrorEmit, MaxLineBytes: 100}
+		if err := ProcessRecords(strings.NewReader(tc.input), &got, cfg, describe); err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if got.String() != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.format, got.String(), tc.want)
+		}
+	}
+
+	// Broken documents stop processing in every mode.
+	for _, tc := range []struct {
+		format, input, want string
+	}{
+		{FormatJSONArray, `{"a":1}`, "json-array: expected a JSON array"},
+		{FormatJSONArray, `[{"a":1},`, "json-array: element 2: unexpected end of JSON input"},
+		{FormatJSONArray, `[1 2]`, "json-array: element 2: invalid character '2' after array element"},
+		{FormatJSONArray, `[1,]`, "json-array: element 2: invalid character ']' looking for beginning of value"},
+		{FormatJSONArray, `["a`, "json-array: element 1: unexpected end of JSON input"},
+		{FormatCSV, "a:date\n", `csv: header: column "a": unknown type "date", expected string, number, bool or json`},
+		{FormatCSV, "a\n\"x\n", "csv: parse error on line 2, column 4: extraneous or missing \" in quoted-field"},
+	} {
+		cfg := Config{Format: tc.format, OnError: OnErrorSkip}
+		err := ProcessRecords(strings.NewReader(tc.input), &bytes.Buffer{}, cfg, describe)
+		if err == nil || err.Error() != tc.want {
+			t.Fatalf("%s %q: expected %q, got %v", tc.format, tc.input, tc.want, err)
+		}
+	}
+
+	if err := ProcessRecords(strings.NewReader(""), &bytes.Buffer{}, Config{Format: "xml"}, describe); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	err := Process(strings.NewReader(""), &bytes.Buffer{}, Config{Format: FormatJSONLEnvelope}, upper)
+	if err == nil {
+		t.Fatalf("expected Process to reject jsonl-envelope")
+	}
+}
+
+func TestProcessRecordsBase64(t *testing.T) {
+	var got bytes.Buffer
+	cfg := Config{Format: FormatJSONLEnvelope, Base64: true}
+	input := `{"header":{"cty":"application/octet-stream"},"payload":"AP/+"}` + "\n"
+	if err := ProcessRecords(strings.NewReader(input), &got, cfg, func(n int, payload []byte, header map[string]any) (string, error) {
+		if !bytes.Equal(payload, []byte{0, 0xff, 0xfe}) || header["cty"] != "application/octet-stream" {
+			return "", errors.New("unexpected record")
+		}
+		return "ok", nil
+	}); err != nil {
+		t.Fatalf("ProcessRecords: %v", err)
+	}
+}
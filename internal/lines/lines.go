@@ -0,0 +1,481 @@
+// SPDX-License-Identifier: MIT
+
+// Package lines runs a per-line transformation, such as signing or
+// encrypting, over an input stream, optionally on a pool of workers.
+package lines
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// bufferSize is the size of the output buffer, large enough to batch
+// hundreds of typical tokens into one write.
+const bufferSize = 64 << 10
+
+// readAheadSize is the number of records read ahead of the processing.
+const readAheadSize = 16
+
+// ErrInterrupted is returned by Process when Config.Done is closed before
+// the input is exhausted.
+var ErrInterrupted = errors.New("interrupted")
+
+// What Process does when a line fails.
+const (
+	// OnErrorFail stops at the first failed line and returns its error.
+	OnErrorFail = "fail"
+	// OnErrorSkip writes the line number and the reason to Config.Errors
+	// and goes on with the next line.
+	OnErrorSkip = "skip"
+	// OnErrorEmit writes an ErrorRecord in place of the result, so that
+	// output lines stay aligned with input lines.
+	OnErrorEmit = "emit"
+)
+
+// OnErrorModes lists the accepted values of Config.OnError.
+var OnErrorModes = []string{OnErrorFail, OnErrorSkip, OnErrorEmit}
+
+// Config controls how Process runs.
+type Config struct {
+	// Workers is the number of lines transformed concurrently; values
+	// below 2 transform lines one by one on the calling goroutine.
+	Workers int
+	// Unordered writes results as soon as they are ready instead of in
+	// input order, which keeps all workers busy when some lines take
+	// longer than others.
+	Unordered bool
+	// Done, when closed, makes Process stop reading input, write the
+	// results of the lines already read and return ErrInterrupted, also
+	// while it waits for more input.
+	Done <-chan struct{}
+	// MaxLineBytes limits the size of an input line; zero means
+	// DefaultMaxLineBytes. A longer line fails with *LineTooLongError.
+	MaxLineBytes int
+	// OnError is one of OnErrorFail (the default when empty), OnErrorSkip
+	// and OnErrorEmit. It applies to errors from Func and to overlong
+	// lines; read and write errors always stop Process.
+	OnError string
+	// Errors receives the failed lines with OnErrorSkip; nil discards them.
+	Errors io.Writer
+	// Stats, when not nil, counts the processed lines.
+	Stats *Stats
+
+	// Raw passes the exact bytes between newlines to Func: lines are not
+	// trimmed and empty lines are processed too.
+	Raw bool
+	// KeepCR keeps the "\r" of "\r\n" line endings in raw lines.
+	KeepCR bool
+	// Base64 decodes each payload as base64, standard or URL-safe
+	// alphabet with optional padding, and passes the decoded bytes on.
+	Base64 bool
+	// Format is the input format, one of Formats; empty means
+	// FormatLines. Raw and KeepCR only apply to FormatLines.
+	Format string
+}
+
+// Stats counts the lines handled by Process.
+type Stats struct {
+	Succeeded int
+	Failed    int
+}
+
+// Processed returns the number of lines handled, not counting the empty
+// lines left out.
+func (s *Stats) Processed() int { return s.Succeeded + s.Failed }
+
+// WriteSummary writes a one-line summary of s to w.
+func (s *Stats) WriteSummary(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "lines: %d processed, %d succeeded, %d failed\n", s.Processed(), s.Succeeded, s.Failed)
+	return err
+}
+
+// LineError is an error for one input line.
+type LineError struct {
+	Line int // 1-based line number
+	Err  error
+}
+
+func (e *LineError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// ErrorRecord is written in place of a failed line with OnErrorEmit.
+type ErrorRecord struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Func transforms the payload from input line n (1-based, counting empty
+// lines) into one output line without the trailing newline. For
+// FormatJSONArray n is the element number. payload is non-empty unless
+// Config.Raw is set, and only valid during the call. With more than one
+// worker Func is called concurrently. Process adds the line number to the
+// errors.
+type Func func(n int, payload []byte) (string, error)
+
+// RecordFunc is like Func but also receives the protected header
+// parameters of a FormatJSONLEnvelope line, nil for other formats.
+type RecordFunc func(n int, payload []byte, header map[string]any) (string, error)
+
+// job is an input record on its way through the pool. done receives the
+// result of fn for the record.
+type job struct {
+	record
+	done chan result
+}
+
+type result struct {
+	n    int
+	out  string
+	err  error
+	idle int // see record.idle
+}
+
+// Process reads payloads from r in cfg.Format, by default one per line,
+// trimmed and without empty lines unless cfg.Raw is set. It transforms
+// them with fn and writes each result followed by a newline to w. Output
+// is buffered and flushed before Process returns, also on errors, so w
+// receives every result produced up to that point. Unless cfg.OnError
+// says otherwise, Process stops at the first error from fn, r or w and
+// returns it; in input order unless cfg.Unordered is set. Errors for a
+// line are *LineError. FormatJSONLEnvelope needs ProcessRecords.
+//
+// Output is also flushed whenever Process has to wait for more input, so
+// results of slowly arriving input, e.g. from tail -f, are not held back.
+func Process(r io.Reader, w io.Writer, cfg Config, fn Func) error {
+	if cfg.Format == FormatJSONLEnvelope {
+		return fmt.Errorf("input format %s is not supported here", cfg.Format)
+	}
+	return ProcessRecords(r, w, cfg, func(n int, payload []byte, _ map[string]any) (string, error) {
+		return fn(n, payload)
+	})
+}
+
+// ProcessRecords is like Process but passes the per-token header of
+// FormatJSONLEnvelope lines to fn.
+func ProcessRecords(r io.Reader, w io.Writer, cfg Config, fn RecordFunc) error {
+	switch cfg.OnError {
+	case "", OnErrorFail, OnErrorSkip, OnErrorEmit:
+	default:
+		return fmt.Errorf("unknown on-error mode %q", cfg.OnError)
+	}
+	in := &idleReader{r: r}
+	src, err := newSource(in, cfg)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	recs, readErr := readAhead(src, in, stop)
+	process := processFunc(cfg, fn)
+	o := &output{w: bufio.NewWriterSize(w, bufferSize), cfg: cfg}
+	if cfg.Workers < 2 {
+		err = processSequential(recs, readErr, o, cfg, process)
+	} else {
+		err = processParallel(recs, readErr, o, cfg, process)
+	}
+	if ferr := o.w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// processFunc returns the function turning a record into a result: it
+// extracts payload and header as cfg.Format says, decodes base64 when
+// asked to, and calls fn.
+func processFunc(cfg Config, fn RecordFunc) func(rec record) result {
+	return func(rec record) result {
+		if rec.idle > 0 {
+			return result{idle: rec.idle}
+		}
+		res := result{n: rec.n, err: rec.err}
+		if res.err != nil {
+			return res
+		}
+		data := rec.data
+		var header map[string]any
+		switch cfg.Format {
+		case FormatJSONLEnvelope:
+			data, header, res.err = parseEnvelope(data)
+		case FormatJSONArray:
+			data, res.err = payload(data)
+		}
+		if res.err == nil && cfg.Base64 {
+			data, res.err = decodeBase64(data)
+		}
+		if res.err == nil {
+			res.out, res.err = fn(rec.n, data, header)
+		}
+		return res
+	}
+}
+
+// output writes results and handles failed lines as cfg.OnError says. It
+// is only used from one goroutine.
+type output struct {
+	w       *bufio.Writer
+	cfg     Config
+	written int // records handled
+	idleAt  int // flush once this many records are handled, if positive
+}
+
+// put handles the result of a record. A result marking idle input flushes
+// the output as soon as the results of all records read before are written,
+// which with Config.Unordered may be after later results.
+func (o *output) put(res result) error {
+	if res.idle > 0 {
+		o.idleAt = res.idle
+	} else {
+		if err := o.write(res); err != nil {
+			return err
+		}
+		o.written++
+	}
+	if o.idleAt > 0 && o.written >= o.idleAt {
+		o.idleAt = 0
+		return o.w.Flush()
+	}
+	return nil
+}
+
+func (o *output) write(res result) error {
+	if res.err == nil {
+		if o.cfg.Stats != nil {
+			o.cfg.Stats.Succeeded++
+		}
+		return writeLine(o.w, res.out)
+	}
+	if o.cfg.Stats != nil {
+		o.cfg.Stats.Failed++
+	}
+	var le *LineError
+	if !errors.As(res.err, &le) {
+		le = &LineError{Line: res.n, Err: res.err}
+	}
+	switch o.cfg.OnError {
+	case OnErrorSkip:
+		if o.cfg.Errors != nil {
+			fmt.Fprintln(o.cfg.Errors, le)
+		}
+		return nil
+	case OnErrorEmit:
+		rec, err := json.Marshal(ErrorRecord{Line: le.Line, Error: le.Err.Error()})
+		if err != nil {
+			return err
+		}
+		return writeLine(o.w, string(rec))
+	default:
+		return le
+	}
+}
+
+// writeLine writes out and a newline without concatenating them.
+func writeLine(w *bufio.Writer, out string) error {
+	if _, err := w.WriteString(out); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// decodeBase64 decodes data as base64 in either alphabet, with or without
+// padding.
+func decodeBase64(data []byte) ([]byte, error) {
+	s := bytes.TrimRight(data, "=")
+	enc := base64.RawStdEncoding
+	if bytes.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	decoded := make([]byte, enc.DecodedLen(len(s)))
+	m, err := enc.Decode(decoded, s)
+	if err != nil {
+		return nil, fmt.Errorf("base64: %w", err)
+	}
+	return decoded[:m], nil
+}
+
+// idleReader calls idle before each read from r, i.e. whenever the
+// buffered input is used up and reading may block until more arrives.
+type idleReader struct {
+	r    io.Reader
+	idle func()
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if r.idle != nil {
+		r.idle()
+	}
+	return r.r.Read(p)
+}
+
+// readAhead reads records from src on its own goroutine, so that callers
+// can wait for input and cfg.Done at the same time. Before src reads from
+// in, it sends a record marking the input idle. The records are copies,
+// safe to keep and to append to. After the last record recs is closed and
+// readErr receives the read error, nil at the end of the input. Closing
+// stop ends reading, though not a read that is blocked already.
+func readAhead(src source, in *idleReader, stop <-chan struct{}) (recs <-chan record, readErr <-chan error) {
+	out := make(chan record, readAheadSize)
+	errc := make(chan error, 1)
+	var read, idle int
+	send := func(rec record) bool {
+		select {
+		case out <- rec:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	in.idle = func() {
+		if read > idle {
+			idle = read
+			send(record{idle: read})
+		}
+	}
+	go func() {
+		defer close(out)
+		for {
+			rec, err := src.next()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errc <- err
+				return
+			}
+			read++
+			rec.data = bytes.Clone(rec.data)
+			if !send(rec) {
+				errc <- nil
+				return
+			}
+		}
+	}()
+	return out, errc
+}
+
+func processSequential(recs <-chan record, readErr <-chan error, o *output, cfg Config, process func(record) result) error {
+	for {
+		select {
+		case <-cfg.Done:
+			return ErrInterrupted
+		default:
+		}
+		var rec record
+		var ok bool
+		select {
+		case rec, ok = <-recs:
+		case <-cfg.Done:
+			return ErrInterrupted
+		}
+		if !ok {
+			return <-readErr
+		}
+		if err := o.put(process(rec)); err != nil {
+			return err
+		}
+	}
+}
+
+func processParallel(recs <-chan record, srcErr <-chan error, o *output, cfg Config, process func(record) result) error {
+	jobs := make(chan job, cfg.Workers)
+	// pending carries jobs to the writer in input order; its capacity
+	// bounds how far the workers may run ahead of the output.
+	pending := make(chan job, 2*cfg.Workers)
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			var rec record
+			var ok bool
+			select {
+			case rec, ok = <-recs:
+			case <-stop:
+				readErr <- nil
+				return
+			case <-cfg.Done:
+				readErr <- ErrInterrupted
+				return
+			}
+			if !ok {
+				readErr <- <-srcErr
+				return
+			}
+			j := job{record: rec, done: make(chan result, 1)}
+			select {
+			case jobs <- j:
+			case <-stop:
+				readErr <- nil
+				return
+			case <-cfg.Done:
+				readErr <- ErrInterrupted
+				return
+			}
+			if !cfg.Unordered {
+				// Cannot block for long: the writer only waits for jobs
+				// already sent to the workers.
+				pending <- j
+			}
+		}
+	}()
+
+	var results chan result
+	if cfg.Unordered {
+		results = make(chan result, cfg.Workers)
+	}
+	var wg sync.WaitGroup
+	for range cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := process(j.record)
+				if results != nil {
+					results <- res
+				} else {
+					j.done <- res
+				}
+			}
+		}()
+	}
+
+	var err error
+	write := func(res result) {
+		if err != nil {
+			return
+		}
+		if err = o.put(res); err != nil {
+			close(stop)
+		}
+	}
+	if cfg.Unordered {
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		for res := range results {
+			write(res)
+		}
+	} else {
+		for j := range pending {
+			if err != nil {
+				continue // drain without waiting for the workers
+			}
+			write(<-j.done)
+		}
+		wg.Wait()
+	}
+
+	if rerr := <-readErr; err == nil {
+		err = rerr
+	}
+	return err
+}
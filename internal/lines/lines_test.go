@@ -0,0 +1,310 @@
+package lines
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// input returns count numbered lines with an empty line after every tenth.
+func input(count int) string {
+	var b strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&b, "  payload-%d \n", i)
+		if i%10 == 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func upper(_ int, line []byte) (string, error) {
+	return string(bytes.ToUpper(line)), nil
+}
+
+// slowUpper makes early lines slower than late ones so that workers
+// finish out of order.
+func slowUpper(n int, line []byte) (string, error) {
+	time.Sleep(time.Duration(200-n%200) * time.Microsecond)
+	return string(bytes.ToUpper(line)), nil
+}
+
+func TestProcessOrdered(t *testing.T) {
+	var want bytes.Buffer
+	if err := Process(strings.NewReader(input(500)), &want, Config{}, upper); err != nil {
+		t.Fatalf("Process sequential: %v", err)
+	}
+	if !strings.HasPrefix(want.String(), "PAYLOAD-1\nPAYLOAD-2\n") || strings.Count(want.String(), "\n") != 500 {
+		t.Fatalf("unexpected sequential output: %q", want.String()[:40])
+	}
+
+	for _, workers := range []int{2, 8} {
+		var got bytes.Buffer
+		if err := Process(strings.NewReader(input(500)), &got, Config{Workers: workers}, slowUpper); err != nil {
+			t.Fatalf("Process with %d workers: %v", workers, err)
+		}
+		if got.String() != want.String() {
+			t.Fatalf("%d workers: output order differs from input order", workers)
+		}
+	}
+}
+
+func TestProcessUnordered(t *testing.T) {
+	var got bytes.Buffer
+	if err := Process(strings.NewReader(input(500)), &got, Config{Workers: 8, Unordered: true}, slowUpper); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(got.String()), "\n") {
+		seen[line] = true
+	}
+	for i := 1; i <= 500; i++ {
+		if !seen[fmt.Sprintf("PAYLOAD-%d", i)] {
+			t.Fatalf("missing line %d in unordered output", i)
+		}
+	}
+}
+
+func TestProcessLineNumbers(t *testing.T) {
+	var got bytes.Buffer
+	fn := func(n int, line []byte) (string, error) { return fmt.Sprintf("%d:%s", n, line), nil }
+	if err := Process(strings.NewReader("a\n\n b\n"), &got, Config{Workers: 2}, fn); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got.String() != "1:a\n3:b\n" {
+		t.Fatalf("unexpected output: %q", got.String())
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	errBad := errors.New("bad line")
+	fn := func(n int, line []byte) (string, error) {
+		if n == 42 || n == 300 {
+			return "", errBad
+		}
+		return string(line), nil
+	}
+	for _, cfg := range []Config{{}, {Workers: 4}, {Workers: 4, Unordered: true}} {
+		var got bytes.Buffer
+		err := Process(strings.NewReader(input(1000)), &got, cfg, fn)
+		if !errors.Is(err, errBad) {
+			t.Fatalf("%+v: expected errBad, got %v", cfg, err)
+		}
+		if !cfg.Unordered && err.Error() != "line 42: bad line" {
+			t.Fatalf("%+v: expected the first error in input order, got %v", cfg, err)
+		}
+		if !cfg.Unordered && !strings.HasSuffix(got.String(), "payload-37\npayload-38\n") {
+			t.Fatalf("%+v: output before the error was not flushed", cfg)
+		}
+	}
+
+	for _, cfg := range []Config{{}, {Workers: 4}, {Workers: 4, Unordered: true}} {
+		if err := Process(strings.NewReader(input(100)), failingWriter{}, cfg, upper); !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf("%+v: expected write error, got %v", cfg, err)
+		}
+		if err := Process(iotest.ErrReader(io.ErrUnexpectedEOF), io.Discard, cfg, upper); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%+v: expected read error, got %v", cfg, err)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestProcessBuffersOutput(t *testing.T) {
+	for _, cfg := range []Config{{}, {Workers: 4}, {Workers: 4, Unordered: true}} {
+		var w countingWriter
+		if err := Process(strings.NewReader(input(1000)), &w, cfg, upper); err != nil {
+			t.Fatalf("%+v: Process: %v", cfg, err)
+		}
+		if strings.Count(w.String(), "\n") != 1000 {
+			t.Fatalf("%+v: expected 1000 lines, got %d", cfg, strings.Count(w.String(), "\n"))
+		}
+		if w.writes != 1 {
+			t.Fatalf("%+v: expected a single write of %d bytes, got %d", cfg, w.Len(), w.writes)
+		}
+	}
+}
+
+func TestProcessInterrupted(t *testing.T) {
+	for _, cfg := range []Config{{}, {Workers: 4}, {Workers: 4, Unordered: true}} {
+		done := make(chan struct{})
+		cfg.Done = done
+		fn := func(n int, line []byte) (string, error) {
+			if n == 50 {
+				close(done)
+			}
+			return string(line), nil
+		}
+		var got bytes.Buffer
+		err := Process(strings.NewReader(input(1000)), &got, cfg, fn)
+		if !errors.Is(err, ErrInterrupted) {
+			t.Fatalf("%+v: expected ErrInterrupted, got %v", cfg, err)
+		}
+		// Lines read before the interrupt are still written and flushed.
+		if !strings.Contains(got.String(), "payload-46\n") || strings.Contains(got.String(), "payload-1000\n") {
+			t.Fatalf("%+v: unexpected output after interrupt: %d bytes", cfg, got.Len())
+		}
+	}
+}
+
+func TestProcessStreaming(t *testing.T) {
+	for _, cfg := range []Config{{}, {Workers: 4}, {Workers: 4, Unordered: true}} {
+		done := make(chan struct{})
+		cfg.Done = done
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+		errc := make(chan error, 1)
+		go func() { errc <- Process(inR, outW, cfg, upper) }()
+
+		// Results are written while the input stays open.
+		out := bufio.NewReader(outR)
+		for _, line := range []string{"a", "b"} {
+			if _, err := io.WriteString(inW, line+"\n"); err != nil {
+				t.Fatalf("%+v: write input: %v", cfg, err)
+			}
+			got, err := out.ReadString('\n')
+			if err != nil || got != strings.ToUpper(line)+"\n" {
+				t.Fatalf("%+v: got %q, %v", cfg, got, err)
+			}
+		}
+
+		// Done stops Process while it waits for input.
+		close(done)
+		select {
+		case err := <-errc:
+			if !errors.Is(err, ErrInterrupted) {
+				t.Fatalf("%+v: expected ErrInterrupted, got %v", cfg, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%+v: Process did not return after Done", cfg)
+		}
+		inW.Close()
+	}
+}
+
+func TestProcessAppendDoesNotClobberInput(t *testing.T) {
+	fn := func(_ int, line []byte) (string, error) {
+		return string(append(line, "!!!!"...)), nil
+	}
+	var got bytes.Buffer
+	if err := Process(strings.NewReader("a\nb\nc\n"), &got, Config{}, fn); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got.String() != "a!!!!\nb!!!!\nc!!!!\n" {
+		t.Fatalf("unexpected output: %q", got.String())
+	}
+}
+
+func TestProcessOnError(t *testing.T) {
+	errOdd := errors.New("odd")
+	fn := func(n int, line []byte) (string, error) {
+		if n%2 == 1 {
+			return "", errOdd
+		}
+		return string(line), nil
+	}
+	input := "l1\nl2\n\nl4\n" + strings.Repeat("x", 100) + "\nl6\n"
+
+	for _, cfg := range []Config{{}, {Workers: 3}} {
+		var out, log bytes.Buffer
+		cfg.OnError, cfg.Errors, cfg.Stats, cfg.MaxLineBytes = OnErrorSkip, &log, &Stats{}, 50
+		if err := Process(strings.NewReader(input), &out, cfg, fn); err != nil {
+			t.Fatalf("skip: %v", err)
+		}
+		if out.String() != "l2\nl4\nl6\n" {
+			t.Fatalf("skip: unexpected output %q", out.String())
+		}
+		wantLog := "line 1: odd\nline 5: 100 bytes exceeds the maximum line size of 50 bytes\n"
+		if log.String() != wantLog {
+			t.Fatalf("skip: unexpected log %q", log.String())
+		}
+		if s := cfg.Stats; s.Processed() != 5 || s.Succeeded != 3 || s.Failed != 2 {
+			t.Fatalf("skip: unexpected stats %+v", *s)
+		}
+
+		out.Reset()
+		cfg.OnError, cfg.Stats = OnErrorEmit, &Stats{}
+		if err := Process(strings.NewReader(input), &out, cfg, fn); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+		want := `{"line":1,"error":"odd"}` + "\nl2\nl4\n" +
+			`{"line":5,"error":"100 bytes exceeds the maximum line size of 50 bytes"}` + "\nl6\n"
+		if out.String() != want {
+			t.Fatalf("emit: unexpected output %q", out.String())
+		}
+		var summary bytes.Buffer
+		if err := cfg.Stats.WriteSummary(&summary); err != nil || summary.String() != "lines: 5 processed, 3 succeeded, 2 failed\n" {
+			t.Fatalf("unexpected summary %q, %v", summary.String(), err)
+		}
+
+		cfg.OnError = OnErrorFail
+		err := Process(strings.NewReader(input), io.Discard, cfg, fn)
+		var le *LineError
+		if !errors.As(err, &le) || le.Line != 1 || !errors.Is(err, errOdd) {
+			t.Fatalf("fail: expected odd on line 1, got %v", err)
+		}
+	}
+
+	if err := Process(strings.NewReader(input), io.Discard, Config{OnError: "ignore"}, fn); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
+
+func TestProcessRaw(t *testing.T) {
+	quote := func(_ int, line []byte) (string, error) { return fmt.Sprintf("%q", line), nil }
+	input := "  padded \t\r\n\nlast"
+	for _, tc := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, "\"padded\"\n\"last\"\n"},
+		{Config{Raw: true}, "\"  padded \\t\"\n\"\"\n\"last\"\n"},
+		{Config{Raw: true, KeepCR: true}, "\"  padded \\t\\r\"\n\"\"\n\"last\"\n"},
+		{Config{Raw: true, KeepCR: true, Workers: 2}, "\"  padded \\t\\r\"\n\"\"\n\"last\"\n"},
+		// KeepCR only applies to raw lines, trimming removes the "\r" anyway.
+		{Config{KeepCR: true}, "\"padded\"\n\"last\"\n"},
+	} {
+		var got bytes.Buffer
+		if err := Process(strings.NewReader(input), &got, tc.cfg, quote); err != nil {
+			t.Fatalf("%+v: Process: %v", tc.cfg, err)
+		}
+		if got.String() != tc.want {
+			t.Fatalf("%+v: got %q, want %q", tc.cfg, got.String(), tc.want)
+		}
+	}
+}
+
+func TestProcessBase64(t *testing.T) {
+	quote := func(_ int, line []byte) (string, error) { return fmt.Sprintf("%q", line), nil }
+	// "\x00\xff\xfe" in every accepted form, then an invalid line.
+	input := "AP/+\nAP_-\n AP/+ \nYQ==\nYQ\n!!\n"
+	var got bytes.Buffer
+	err := Process(strings.NewReader(input), &got, Config{Base64: true, OnError: OnErrorEmit}, quote)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	want := "\"\\x00\\xff\\xfe\"\n\"\\x00\\xff\\xfe\"\n\"\\x00\\xff\\xfe\"\n\"a\"\n\"a\"\n" +
+		`{"line":6,"error":"base64: illegal base64 data at input byte 0"}` + "\n"
+	if got.String() != want {
+		t.Fatalf("got %q, want %q", got.String(), want)
+	}
+}
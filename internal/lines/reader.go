@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MIT
+
+package lines
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineBytes is the line size limit used when none is given. It
+// leaves room for claim sets with large arrays or embedded certificates.
+const DefaultMaxLineBytes = 16 << 20
+
+// readBufferSize is the size of the input buffer. Lines that fit are
+// returned without copying.
+const readBufferSize = 64 << 10
+
+// LineTooLongError reports an input line longer than the limit. Reader
+// returns it wrapped in a *LineError naming the line.
+type LineTooLongError struct {
+	Size int64 // line length in bytes, without the line terminator
+	Max  int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("%d bytes exceeds the maximum line size of %d bytes", e.Size, e.Max)
+}
+
+// Reader reads newline-terminated lines of up to a maximum size. Unlike
+// bufio.Scanner it tells the number and size of a line that is too long.
+type Reader struct {
+	// KeepCR keeps the "\r" of a "\r\n" terminator in the line.
+	KeepCR bool
+
+	r    *bufio.Reader
+	max  int
+	line int
+	buf  []byte
+}
+
+// NewReader returns a Reader for lines of at most maxLineBytes bytes, or
+// DefaultMaxLineBytes when maxLineBytes is not positive.
+func NewReader(r io.Reader, maxLineBytes int) *Reader {
+	if maxLineBytes <= 0 {
+		maxLineBytes = DefaultMaxLineBytes
+	}
+	return &Reader{r: bufio.NewReaderSize(r, readBufferSize), max: maxLineBytes}
+}
+
+// Line returns the number of the line last returned by Next.
+func (r *Reader) Line() int { return r.line }
+
+// Next returns the next line without its "\n" or "\r\n" terminator (only
+// "\n" with KeepCR), or io.EOF after the last one. The line is only valid
+// until the next call. A line longer than the limit is skipped and
+// reported as a *LineError wrapping *LineTooLongError; reading may
+// continue with the following line.
+func (r *Reader) Next() ([]byte, error) {
+	r.line++
+	r.buf = r.buf[:0]
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			// Lines longer than the buffer are gathered in r.buf, which
+			// grows by doubling and is reused for later lines.
+			r.buf = append(r.buf, chunk...)
+			if len(r.buf) > r.max {
+				return nil, r.skip()
+			}
+			continue
+		case err == io.EOF && len(r.buf)+len(chunk) == 0:
+			r.line--
+			return nil, io.EOF
+		case err != nil && err != io.EOF:
+			return nil, err
+		}
+		line := chunk
+		if len(r.buf) > 0 {
+			r.buf = append(r.buf, chunk...)
+			line = r.buf
+		}
+		line = r.trimTerminator(line)
+		if len(line) > r.max {
+			return nil, r.tooLong(int64(len(line)))
+		}
+		return line, nil
+	}
+}
+
+// skip discards the rest of an overlong line, of which the bytes in r.buf
+// were read already, and returns the error describing it.
+func (r *Reader) skip() error {
+	size := int64(len(r.buf))
+	// tail keeps the last bytes read to find the terminator.
+	tail := lastTwo(nil, r.buf)
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		size += int64(len(chunk))
+		tail = lastTwo(tail, chunk)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		break
+	}
+	size -= int64(len(tail) - len(r.trimTerminator(tail)))
+	return r.tooLong(size)
+}
+
+func (r *Reader) tooLong(size int64) error {
+	return &LineError{Line: r.line, Err: &LineTooLongError{Size: size, Max: r.max}}
+}
+
+// lastTwo returns the last two bytes of tail followed by b.
+func lastTwo(tail, b []byte) []byte {
+	if len(b) >= 2 {
+		return append(tail[:0], b[len(b)-2:]...)
+	}
+	tail = append(tail, b...)
+	if len(tail) > 2 {
+		tail = append(tail[:0], tail[len(tail)-2:]...)
+	}
+	return tail
+}
+
+func (r *Reader) trimTerminator(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n > 1 && line[n-2] == '\r' && !r.KeepCR {
+			line = line[:n-2]
+		}
+	}
+	return line
+}
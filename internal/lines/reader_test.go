@@ -0,0 +1,108 @@
+package lines
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	big := strings.Repeat("x", 3*readBufferSize+17)
+	input := "a\r\n\n" + big + "\nb\r\n" + big + "\r\nlast"
+	want := []string{"a", "", big, "b", big, "last"}
+
+	r := NewReader(strings.NewReader(input), 0)
+	for i, w := range want {
+		line, err := r.Next()
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if string(line) != w || r.Line() != i+1 {
+			t.Fatalf("line %d: got %d bytes as line %d, want %d bytes", i+1, len(line), r.Line(), len(w))
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if r.Line() != len(want) {
+		t.Fatalf("Line after EOF: got %d", r.Line())
+	}
+}
+
+func TestReaderLineTooLong(t *testing.T) {
+	const max = 100
+	long := strings.Repeat("y", 5*readBufferSize)
+	input := strings.Repeat("z", max) + "\n" +
+		strings.Repeat("z", max+1) + "\n" +
+		long + "\r\n" +
+		"ok\n" +
+		long
+	r := NewReader(strings.NewReader(input), max)
+
+	wantErrs := map[int]int64{2: max + 1, 3: int64(len(long)), 5: int64(len(long))}
+	for n := 1; n <= 5; n++ {
+		line, err := r.Next()
+		size, tooLong := wantErrs[n]
+		if !tooLong {
+			if err != nil || len(line) > max {
+				t.Fatalf("line %d: %d bytes, %v", n, len(line), err)
+			}
+			continue
+		}
+		var le *LineError
+		var e *LineTooLongError
+		if !errors.As(err, &le) || !errors.As(err, &e) {
+			t.Fatalf("line %d: expected *LineTooLongError, got %v", n, err)
+		}
+		if le.Line != n || e.Size != size || e.Max != max {
+			t.Fatalf("line %d: unexpected error %v", n, err)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestProcessMaxLineBytes(t *testing.T) {
+	input := "short\n" + strings.Repeat("x", 100<<10) + "\nshort\n"
+	for _, cfg := range []Config{{}, {Workers: 4}} {
+		var got bytes.Buffer
+		if err := Process(strings.NewReader(input), &got, cfg, upper); err != nil {
+			t.Fatalf("%+v: Process: %v", cfg, err)
+		}
+		if strings.Count(got.String(), "\n") != 3 {
+			t.Fatalf("%+v: expected 3 lines", cfg)
+		}
+
+		got.Reset()
+		cfg.MaxLineBytes = 64 << 10
+		err := Process(strings.NewReader(input), &got, cfg, upper)
+		var le *LineError
+		var e *LineTooLongError
+		if !errors.As(err, &le) || !errors.As(err, &e) || le.Line != 2 || e.Size != 100<<10 {
+			t.Fatalf("%+v: expected line 2 too long, got %v", cfg, err)
+		}
+		if err.Error() != "line 2: 102400 bytes exceeds the maximum line size of 65536 bytes" {
+			t.Fatalf("unexpected message: %v", err)
+		}
+		if got.String() != "SHORT\n" {
+			t.Fatalf("%+v: expected the output before line 2, got %q", cfg, got.String())
+		}
+	}
+}
+
+func TestReaderKeepCR(t *testing.T) {
+	r := NewReader(strings.NewReader("a\r\nb\r\r\n\r\n"), 0)
+	r.KeepCR = true
+	for _, want := range []string{"a\r", "b\r\r", "\r"} {
+		line, err := r.Next()
+		if err != nil || string(line) != want {
+			t.Fatalf("got %q, %v, want %q", line, err, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+
+// Package nested produces nested JWTs (RFC 7519 section 5.2): a JWS that
+// is encrypted as the payload of a JWE with the "cty" header "JWT".
+package nested
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+// Encoder signs payloads and encrypts the resulting JWS.
+type Encoder struct {
+	signer    sign.Signer
+	encrypter *encrypt.Encrypter
+	replicate []string
+}
+
+// New returns an Encoder signing with s and encrypting with e. The claims
+// named in replicate are copied from each payload into the JWE header as
+// RFC 7519 section 5.3 describes, so that recipients can route a token
+// before decrypting it. Claims missing from a payload are skipped. Names
+// are trimmed of surrounding spaces; registered JWE header names cannot
+// be replicated.
+func New(s sign.Signer, e *encrypt.Encrypter, replicate ...string) (*Encoder, error) {
+	names := make([]string, 0, len(replicate))
+	for _, name := range replicate {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("empty claim name to replicate")
+		}
+		if slices.Contains(jweHeaders, name) {
+			return nil, fmt.Errorf("claim %q cannot be replicated: it names a registered JWE header", name)
+		}
+		names = append(names, name)
+	}
+	return &Encoder{signer: s, encrypter: e, replicate: names}, nil
+}
+
+// jweHeaders are the header parameters registered by RFC 7516 section 4.1
+// and RFC 7518 section 4, which a replicated claim would clash with.
+var jweHeaders = []string{
+	"alg", "enc", "zip", "jku", "jwk", "kid", "x5u", "x5c", "x5t", "x5t#S256",
+	"typ", "cty", "crit", "epk", "apu", "apv", "iv", "tag", "p2s", "p2c",
+}
+
+// Encode signs payload and returns the nested JWT.
+func (n *Encoder) Encode(payload []byte) (string, error) {
+	return n.EncodeWithHeaders(payload, nil)
+}
+
+// EncodeWithHeaders is like Encode but adds jwsHeaders to the header of
+// the inner JWS of this token only, see sign.Signer.SignWithHeaders.
+func (n *Encoder) EncodeWithHeaders(payload []byte, jwsHeaders map[string]any) (string, error) {
+	headers := map[string]any{"cty": "JWT"}
+	if len(n.replicate) > 0 {
+		var claims map[string]any
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		if err := dec.Decode(&claims); err != nil {
+			return "", fmt.Errorf("replicate claims: payload is not a JSON object: %w", err)
+		}
+		for _, name := range n.replicate {
+			if value, ok := claims[name]; ok {
+				headers[name] = value
+			}
+		}
+	}
+
+	jws, err := n.signer.SignWithHeaders(payload, jwsHeaders)
+	if err != nil {
+		return "", fmt.Errorf("sign: %w", err)
+	}
+	jwe, err := n.encrypter.EncryptWithHeaders([]byte(jws), headers)
+	if err != nil {
+		return "", fmt.Errorf("encrypt: %w", err)
+	}
+	return jwe, nil
+}
+
+// EncodeLines reads non-empty lines from r, encodes each line with n, and
+// writes resulting nested JWTs to w. cfg selects the input format, the
+// number of workers, the output order, how lines become payloads and what
+// happens to lines that fail, see lines.Config. The header of a
+// jsonl-envelope line is added to its inner JWS, see EncodeWithHeaders.
+func EncodeLines(r io.Reader, w io.Writer, n *Encoder, cfg lines.Config) error {
+	return lines.ProcessRecords(r, w, cfg, func(_ int, payload []byte, header map[string]any) (string, error) {
+		return n.EncodeWithHeaders(payload, header)
+	})
+}
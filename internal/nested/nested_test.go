@@ -0,0 +1,131 @@
+package nested
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/danilkiff/jwt-token-generator/internal/encrypt"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+var (
+	testSecret = []byte("0123456789abcdef0123456789abcdef")
+	testKEK    = bytes.Repeat([]byte{5}, 32)
+)
+
+func newEncoder(t *testing.T, replicate ...string) *Encoder {
+	t.Helper()
+	s, err := sign.NewSigner("HS256", testSecret)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	e, err := encrypt.NewEncrypter(jose.A256KW, testKEK, encrypt.WithHeader("kid", "enc-1"))
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	n, err := New(s, e, replicate...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return n
+}
+
+func TestEncode(t *testing.T) {
+	n := newEncoder(t, "iss", " sub", "aud ", "jti")
+	payload := `{"iss":"https://issuer.example","sub":"alice","aud":["api","admin"],"exp":1700000900}`
+	token, err := n.Encode([]byte(payload))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	jws, hdr, err := jose.Decode(token, testKEK)
+	if err != nil {
+		t.Fatalf("jose.Decode JWE: %v", err)
+	}
+	if hdr["cty"] != "JWT" || hdr["kid"] != "enc-1" || hdr["iss"] != "https://issuer.example" || hdr["sub"] != "alice" {
+		t.Fatalf("unexpected JWE header: %v", hdr)
+	}
+	if aud, _ := hdr["aud"].([]any); len(aud) != 2 || aud[1] != "admin" {
+		t.Fatalf("unexpected replicated aud: %v", hdr["aud"])
+	}
+	if _, ok := hdr["jti"]; ok {
+		t.Fatalf("missing claim must not be replicated: %v", hdr)
+	}
+
+	got, inner, err := jose.Decode(jws, testSecret)
+	if err != nil {
+		t.Fatalf("jose.Decode JWS: %v", err)
+	}
+	if got != payload || inner["alg"] != "HS256" {
+		t.Fatalf("unexpected inner token: %q %v", got, inner)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := newEncoder(t, "sub").Encode([]byte("not json")); err == nil ||
+		!strings.Contains(err.Error(), "payload is not a JSON object") {
+		t.Fatalf("expected JSON error, got %v", err)
+	}
+	// Without replicated claims any payload can be nested.
+	if _, err := newEncoder(t).Encode([]byte("not json")); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	s, err := sign.NewSigner("HS256", testSecret)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	e, err := encrypt.NewEncrypter(jose.RSA_OAEP_256, &priv.PublicKey)
+	if err != nil {
+		t.Fatalf("NewEncrypter: %v", err)
+	}
+	for _, name := range []string{"", " ", "enc", "cty", " kid", "typ", "x5t#S256", "epk", "p2c"} {
+		if _, err := New(s, e, name); err == nil {
+			t.Fatalf("expected error replicating %q", name)
+		}
+	}
+}
+
+func TestEncodeLines(t *testing.T) {
+	n := newEncoder(t)
+	var out bytes.Buffer
+	if err := EncodeLines(strings.NewReader("{\"a\":1}\n\n{\"b\":2}\n"), &out, n, lines.Config{Workers: 2}); err != nil {
+		t.Fatalf("EncodeLines: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !encrypt.IsCompactJWE(lines[0]) {
+		t.Fatalf("expected 2 nested tokens, got %q", out.String())
+	}
+}
+
+func TestEncodeLinesEnvelope(t *testing.T) {
+	n := newEncoder(t)
+	in := `{"header":{"kid":"sig-1","typ":"at+jwt"},"payload":{"sub":"a"}}` + "\n"
+	var out bytes.Buffer
+	if err := EncodeLines(strings.NewReader(in), &out, n, lines.Config{Format: lines.FormatJSONLEnvelope}); err != nil {
+		t.Fatalf("EncodeLines: %v", err)
+	}
+	jws, hdr, err := jose.Decode(strings.TrimSpace(out.String()), testKEK)
+	if err != nil {
+		t.Fatalf("jose.Decode JWE: %v", err)
+	}
+	if hdr["kid"] != "enc-1" || hdr["typ"] != nil {
+		t.Fatalf("envelope header must only go to the JWS, got JWE header %v", hdr)
+	}
+	payload, inner, err := jose.Decode(jws, testSecret)
+	if err != nil {
+		t.Fatalf("jose.Decode JWS: %v", err)
+	}
+	if payload != `{"sub":"a"}` || inner["kid"] != "sig-1" || inner["typ"] != "at+jwt" {
+		t.Fatalf("unexpected inner token: %q %v", payload, inner)
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: MIT
+
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// ES256K is the JOSE name of ECDSA using secp256k1 and SHA-256 (RFC 8812).
+const ES256K = "ES256K"
+
+// es256kAlgorithm implements jose.JwsAlgorithm for ES256K. Signatures use
+// the JOSE raw R||S encoding with 32-byte halves.
+type es256kAlgorithm struct{}
+
+func (a *es256kAlgorithm) Name() string { return ES256K }
+
+func (a *es256kAlgorithm) Sign(securedInput []byte, key interface{}) ([]byte, error) {
+	privKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || !isSecp256k1(privKey.Curve) {
+		return nil, fmt.Errorf("ES256K Sign: expected secp256k1 *ecdsa.PrivateKey, got %T", key)
+	}
+	digest := sha256.Sum256(securedInput)
+	r, s, err := ecdsa.Sign(rand.Reader, privKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+func (a *es256kAlgorithm) Verify(securedInput, signature []byte, key interface{}) error {
+	pubKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || !isSecp256k1(pubKey.Curve) {
+		return fmt.Errorf("ES256K Verify: expected secp256k1 *ecdsa.PublicKey, got %T", key)
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("ES256K Verify: expected 64-byte signature, got %d bytes", len(signature))
+	}
+	digest := sha256.Sum256(securedInput)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(pubKey, digest[:], r, s) {
+		return fmt.Errorf("ES256K signature verification failed")
+	}
+	return nil
+}
+
+// isSecp256k1 reports whether c is the secp256k1 curve.
+func isSecp256k1(c elliptic.Curve) bool {
+	return c != nil && c.Params().Name == secp256k1.S256().Params().Name
+}
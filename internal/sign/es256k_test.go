@@ -0,0 +1,84 @@
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+// ASN.1 structures for building secp256k1 test keys, which crypto/x509
+// cannot marshal.
+var (
+	oidSecp256k1      = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+)
+
+type sec1PrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+type pkcs8PrivateKey struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+func TestParseSecp256k1PKCS8AndDecode(t *testing.T) {
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey secp256k1: %v", err)
+	}
+	sec1, err := asn1.Marshal(sec1PrivateKey{Version: 1, PrivateKey: priv.Serialize()})
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	params, err := asn1.Marshal(oidSecp256k1)
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	der, err := asn1.Marshal(pkcs8PrivateKey{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: params}},
+		PrivateKey: sec1,
+	})
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	token, err := SignEC(`{"g":7}`, ES256K, privPEM)
+	if err != nil {
+		t.Fatalf("SignEC error: %v", err)
+	}
+	key, err := parsePrivateKey(privPEM, nil)
+	if err != nil {
+		t.Fatalf("parsePrivateKey: %v", err)
+	}
+	payload, hdr, err := jose.Decode(token, &key.(*ecdsa.PrivateKey).PublicKey)
+	if err != nil {
+		t.Fatalf("jose.Decode: %v", err)
+	}
+	if hdr["alg"] != ES256K || payload != `{"g":7}` {
+		t.Fatalf("unexpected token: %v %q", hdr, payload)
+	}
+}
+
+func TestSignES256KErrors(t *testing.T) {
+	if _, err := SignEC(`{}`, ES256K, nil); err == nil {
+		t.Fatalf("expected error for empty key")
+	}
+	if _, err := SignEC(`{}`, ES256K, []byte("nope")); err == nil {
+		t.Fatalf("expected error for bad PEM")
+	}
+	p256 := genECPrivatePEM(t)
+	if _, err := SignEC(`{}`, ES256K, p256); err == nil {
+		t.Fatalf("expected error for P-256 key")
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"math/big"
+)
+
+// The algorithms of RFC 7518 section 3 as explicit jose.JwsAlgorithm
+// instances. jose2go implements them too, but only behind its global
+// registry, which offers no lookup.
+
+// hmacAlgorithm implements jose.JwsAlgorithm for HS256, HS384 and HS512.
+type hmacAlgorithm struct {
+	name string
+	hash crypto.Hash
+}
+
+func (a *hmacAlgorithm) Name() string { return a.name }
+
+func (a *hmacAlgorithm) Sign(securedInput []byte, key interface{}) ([]byte, error) {
+	secret, ok := key.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%s Sign: expected []byte secret, got %T", a.name, key)
+	}
+	mac := hmac.New(a.hash.New, secret)
+	mac.Write(securedInput)
+	return mac.Sum(nil), nil
+}
+
+func (a *hmacAlgorithm) Verify(securedInput, signature []byte, key interface{}) error {
+	expected, err := a.Sign(securedInput, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return fmt.Errorf("%s signature verification failed", a.name)
+	}
+	return nil
+}
+
+// rsaAlgorithm implements jose.JwsAlgorithm for RSASSA-PKCS1-v1_5 (RS256,
+// RS384, RS512) and, with pss set, RSASSA-PSS with a salt as long as the
+// hash (PS256, PS384, PS512).
+type rsaAlgorithm struct {
+	name string
+	hash crypto.Hash
+	pss  bool
+}
+
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+
+func (a *rsaAlgorithm) Name() string { return a.name }
+
+func (a *rsaAlgorithm) Sign(securedInput []byte, key interface{}) ([]byte, error) {
+	privKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s Sign: expected *rsa.PrivateKey, got %T", a.name, key)
+	}
+	digest := a.digest(securedInput)
+	if a.pss {
+		return rsa.SignPSS(rand.Reader, privKey, a.hash, digest, pssOptions)
+	}
+	return rsa.SignPKCS1v15(rand.Reader, privKey, a.hash, digest)
+}
+
+func (a *rsaAlgorithm) Verify(securedInput, signature []byte, key interface{}) error {
+	pubKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%s Verify: expected *rsa.PublicKey, got %T", a.name, key)
+	}
+	digest := a.digest(securedInput)
+	if a.pss {
+		return rsa.VerifyPSS(pubKey, a.hash, digest, signature, pssOptions)
+	}
+	return rsa.VerifyPKCS1v15(pubKey, a.hash, digest, signature)
+}
+
+func (a *rsaAlgorithm) digest(securedInput []byte) []byte {
+	h := a.hash.New()
+	h.Write(securedInput)
+	return h.Sum(nil)
+}
+
+// ecdsaAlgorithm implements jose.JwsAlgorithm for ES256, ES384 and ES512.
+// Signatures use the JOSE raw R||S encoding with halves of size bytes.
+type ecdsaAlgorithm struct {
+	name string
+	hash crypto.Hash
+	size int
+}
+
+func (a *ecdsaAlgorithm) Name() string { return a.name }
+
+func (a *ecdsaAlgorithm) Sign(securedInput []byte, key interface{}) ([]byte, error) {
+	privKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || curveBytes(&privKey.PublicKey) != a.size {
+		return nil, fmt.Errorf("%s Sign: expected *ecdsa.PrivateKey with %d-byte coordinates, got %T", a.name, a.size, key)
+	}
+	h := a.hash.New()
+	h.Write(securedInput)
+	r, s, err := ecdsa.Sign(rand.Reader, privKey, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 2*a.size)
+	r.FillBytes(sig[:a.size])
+	s.FillBytes(sig[a.size:])
+	return sig, nil
+}
+
+func (a *ecdsaAlgorithm) Verify(securedInput, signature []byte, key interface{}) error {
+	pubKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || curveBytes(pubKey) != a.size {
+		return fmt.Errorf("%s Verify: expected *ecdsa.PublicKey with %d-byte coordinates, got %T", a.name, a.size, key)
+	}
+	if len(signature) != 2*a.size {
+		return fmt.Errorf("%s Verify: expected %d-byte signature, got %d bytes", a.name, 2*a.size, len(signature))
+	}
+	h := a.hash.New()
+	h.Write(securedInput)
+	r := new(big.Int).SetBytes(signature[:a.size])
+	s := new(big.Int).SetBytes(signature[a.size:])
+	if !ecdsa.Verify(pubKey, h.Sum(nil), r, s) {
+		return fmt.Errorf("%s signature verification failed", a.name)
+	}
+	return nil
+}
+
+// curveBytes returns the coordinate size of key's curve in bytes.
+func curveBytes(key *ecdsa.PublicKey) int {
+	if key.Curve == nil {
+		return 0
+	}
+	return (key.Curve.Params().BitSize + 7) / 8
+}
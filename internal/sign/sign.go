@@ -1,27 +1,23 @@
 // SPDX-License-Identifier: MIT
 
 // Package sign provides helper functions for signing payloads as JWTs
-// using HS256, RS256, ES256, and EdDSA.
+// using HS256/HS384/HS512, RS256/RS384/RS512, PS256/PS384/PS512,
+// ES256/ES384/ES512, ES256K, and EdDSA.
+//
+// Algorithms are looked up by their JOSE name in a registry, see Signer,
+// NewSigner and ParseSigner. The SignX/SignLinesX helpers are shortcuts
+// for the built-in algorithms.
 package sign
 
 import (
-	"bufio"
 	"crypto/ed25519"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 	"io"
-	"strings"
 
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
 	jose "github.com/dvsekhvalnov/jose2go"
-	ecc "github.com/dvsekhvalnov/jose2go/keys/ecc"
-	Rsa "github.com/dvsekhvalnov/jose2go/keys/rsa"
 )
 
-func init() {
-	jose.RegisterJws(&edDSAAlgorithm{})
-}
-
 // edDSAAlgorithm implements jose.JwsAlgorithm for EdDSA (Ed25519).
 type edDSAAlgorithm struct{}
 
@@ -46,188 +42,173 @@ func (a *edDSAAlgorithm) Verify(securedInput, signature []byte, key interface{})
 	return nil
 }
 
+// signPayload signs a single payload string with the named algorithm and
+// encoded key material.
+func signPayload(payload, alg string, keyData []byte, opts ...Option) (string, error) {
+	s, err := ParseSigner(alg, keyData, opts...)
+	if err != nil {
+		return "", err
+	}
+	return s.Sign([]byte(payload))
+}
+
+// signLines parses keyData once and signs every line from r with the
+// named algorithm, as configured by WithLines.
+func signLines(r io.Reader, w io.Writer, alg string, keyData []byte, opts ...Option) error {
+	s, err := ParseSigner(alg, keyData, opts...)
+	if err != nil {
+		return err
+	}
+	var o signerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return SignLines(r, w, s, o.lines)
+}
+
+// checkKeyType reports an error unless alg is a registered algorithm
+// using keys of the given JWK key type.
+func checkKeyType(alg, keyType string) error {
+	a, ok := Lookup(alg)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownAlg, alg)
+	}
+	if a.KeyType != keyType {
+		return fmt.Errorf("algorithm %s does not use %s keys", alg, keyType)
+	}
+	return nil
+}
+
+// parsePrivateKey parses a PEM private key for the RSA, EC and OKP
+// algorithms; CheckKey then rejects keys of the wrong type or curve.
+func parsePrivateKey(data, passphrase []byte) (any, error) {
+	return keys.ParsePrivateKey(data, passphrase)
+}
+
 // -----------------------------------------------------------------------------
-// HS256
+// HMAC (HS256, HS384, HS512)
 // -----------------------------------------------------------------------------
 
-// SignHS256 signs a single payload string using HS256 and a shared secret key.
-func SignHS256(payload string, secret []byte) (string, error) {
-	if len(secret) == 0 {
-		return "", fmt.Errorf("secret must not be empty")
-	}
-	token, err := jose.Sign(payload, jose.HS256, secret)
-	if err != nil {
-		return "", err
+// parseSecret returns a copy of the shared secret bytes.
+func parseSecret(data, _ []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
 	}
-	return token, nil
+	return append([]byte(nil), data...), nil
+}
+
+// SignHS256 signs a single payload string using HS256 and a shared secret
+// key of at least 256 bits.
+func SignHS256(payload string, secret []byte) (string, error) {
+	return signPayload(payload, jose.HS256, secret)
 }
 
 // SignLinesHS256 reads non-empty lines from r, signs each line with HS256,
 // and writes resulting JWTs to w.
 func SignLinesHS256(r io.Reader, w io.Writer, secret []byte) error {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		tok, err := SignHS256(line, secret)
-		if err != nil {
-			return err
-		}
-		if _, err := io.WriteString(w, tok+"\n"); err != nil {
-			return err
-		}
-	}
-	return scanner.Err()
+	return signLines(r, w, jose.HS256, secret)
 }
 
-// -----------------------------------------------------------------------------
-// RS256
-// -----------------------------------------------------------------------------
+// SignHMAC signs a single payload string using an HMAC algorithm (HS256,
+// HS384 or HS512) and a shared secret. The secret must be at least as long
+// as the hash output unless AllowWeakKey is given.
+func SignHMAC(payload, alg string, secret []byte, opts ...Option) (string, error) {
+	if err := checkKeyType(alg, "oct"); err != nil {
+		return "", err
+	}
+	return signPayload(payload, alg, secret, opts...)
+}
 
-// parseRSAPrivateKey parses an RSA private key from PEM-encoded bytes.
-func parseRSAPrivateKey(pemBytes []byte) (interface{}, error) {
-	if len(pemBytes) == 0 {
-		return nil, fmt.Errorf("empty RSA private key")
+// SignLinesHMAC reads non-empty lines from r, signs each line with the given
+// HMAC algorithm, and writes resulting JWTs to w.
+func SignLinesHMAC(r io.Reader, w io.Writer, alg string, secret []byte, opts ...Option) error {
+	if err := checkKeyType(alg, "oct"); err != nil {
+		return err
 	}
-	return Rsa.ReadPrivate(pemBytes)
+	return signLines(r, w, alg, secret, opts...)
 }
 
+// -----------------------------------------------------------------------------
+// RSA (RS256, RS384, RS512, PS256, PS384, PS512)
+// -----------------------------------------------------------------------------
+
 // SignRS256 signs a single payload string using RS256 and an RSA private
 // key in PEM format.
 func SignRS256(payload string, privPEM []byte) (string, error) {
-	key, err := parseRSAPrivateKey(privPEM)
-	if err != nil {
-		return "", err
-	}
-	return jose.Sign(payload, jose.RS256, key)
+	return signPayload(payload, jose.RS256, privPEM)
 }
 
 // SignLinesRS256 reads non-empty lines from r, signs each line with RS256,
 // and writes resulting JWTs to w.
 func SignLinesRS256(r io.Reader, w io.Writer, privPEM []byte) error {
-	key, err := parseRSAPrivateKey(privPEM)
-	if err != nil {
-		return err
+	return signLines(r, w, jose.RS256, privPEM)
+}
+
+// SignRSA signs a single payload string using an RSA algorithm (RS256,
+// RS384, RS512, PS256, PS384 or PS512) and an RSA private key in PEM format.
+func SignRSA(payload, alg string, privPEM []byte, opts ...Option) (string, error) {
+	if err := checkKeyType(alg, "RSA"); err != nil {
+		return "", err
 	}
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		tok, err := jose.Sign(line, jose.RS256, key)
-		if err != nil {
-			return err
-		}
-		if _, err := io.WriteString(w, tok+"\n"); err != nil {
-			return err
-		}
+	return signPayload(payload, alg, privPEM, opts...)
+}
+
+// SignLinesRSA reads non-empty lines from r, signs each line with the given
+// RSA algorithm, and writes resulting JWTs to w.
+func SignLinesRSA(r io.Reader, w io.Writer, alg string, privPEM []byte, opts ...Option) error {
+	if err := checkKeyType(alg, "RSA"); err != nil {
+		return err
 	}
-	return scanner.Err()
+	return signLines(r, w, alg, privPEM, opts...)
 }
 
 // -----------------------------------------------------------------------------
-// ES256
+// ECDSA (ES256, ES384, ES512, ES256K)
 // -----------------------------------------------------------------------------
 
-// parseECPrivateKey parses an EC private key from PEM-encoded bytes.
-func parseECPrivateKey(pemBytes []byte) (interface{}, error) {
-	if len(pemBytes) == 0 {
-		return nil, fmt.Errorf("empty EC private key")
-	}
-	return ecc.ReadPrivate(pemBytes)
-}
-
 // SignES256 signs a single payload string using ES256 and an EC private
 // key in PEM format.
 func SignES256(payload string, privPEM []byte) (string, error) {
-	key, err := parseECPrivateKey(privPEM)
-	if err != nil {
-		return "", err
-	}
-	return jose.Sign(payload, jose.ES256, key)
+	return signPayload(payload, jose.ES256, privPEM)
 }
 
 // SignLinesES256 reads non-empty lines from r, signs each line with ES256,
 // and writes resulting JWTs to w.
 func SignLinesES256(r io.Reader, w io.Writer, privPEM []byte) error {
-	key, err := parseECPrivateKey(privPEM)
-	if err != nil {
-		return err
+	return signLines(r, w, jose.ES256, privPEM)
+}
+
+// SignEC signs a single payload string using an ECDSA algorithm (ES256,
+// ES384, ES512 or ES256K) and an EC private key in PEM format. The key
+// must be on the curve required by the algorithm.
+func SignEC(payload, alg string, privPEM []byte, opts ...Option) (string, error) {
+	if err := checkKeyType(alg, "EC"); err != nil {
+		return "", err
 	}
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		tok, err := jose.Sign(line, jose.ES256, key)
-		if err != nil {
-			return err
-		}
-		if _, err := io.WriteString(w, tok+"\n"); err != nil {
-			return err
-		}
+	return signPayload(payload, alg, privPEM, opts...)
+}
+
+// SignLinesEC reads non-empty lines from r, signs each line with the given
+// ECDSA algorithm, and writes resulting JWTs to w.
+func SignLinesEC(r io.Reader, w io.Writer, alg string, privPEM []byte, opts ...Option) error {
+	if err := checkKeyType(alg, "EC"); err != nil {
+		return err
 	}
-	return scanner.Err()
+	return signLines(r, w, alg, privPEM, opts...)
 }
 
 // -----------------------------------------------------------------------------
 // EdDSA (Ed25519)
 // -----------------------------------------------------------------------------
 
-// parseEdPrivateKey parses an Ed25519 private key from PEM-encoded PKCS8 bytes.
-func parseEdPrivateKey(pemBytes []byte) (ed25519.PrivateKey, error) {
-	if len(pemBytes) == 0 {
-		return nil, fmt.Errorf("empty Ed25519 private key")
-	}
-	block, _ := pem.Decode(pemBytes)
-	if block == nil {
-		return nil, fmt.Errorf("failed to decode PEM block")
-	}
-	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("parse PKCS8 private key: %w", err)
-	}
-	edKey, ok := key.(ed25519.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("key is not Ed25519, got %T", key)
-	}
-	return edKey, nil
-}
-
 // SignEdDSA signs a single payload string using EdDSA and an Ed25519 private
-// key in PEM format (PKCS8).
-func SignEdDSA(payload string, privPEM []byte) (string, error) {
-	key, err := parseEdPrivateKey(privPEM)
-	if err != nil {
-		return "", err
-	}
-	return jose.Sign(payload, "EdDSA", key)
+// key in PEM format.
+func SignEdDSA(payload string, privPEM []byte, opts ...Option) (string, error) {
+	return signPayload(payload, "EdDSA", privPEM, opts...)
 }
 
 // SignLinesEdDSA reads non-empty lines from r, signs each line with EdDSA,
 // and writes resulting JWTs to w.
-func SignLinesEdDSA(r io.Reader, w io.Writer, privPEM []byte) error {
-	key, err := parseEdPrivateKey(privPEM)
-	if err != nil {
-		return err
-	}
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		tok, err := jose.Sign(line, "EdDSA", key)
-		if err != nil {
-			return err
-		}
-		if _, err := io.WriteString(w, tok+"\n"); err != nil {
-			return err
-		}
-	}
-	return scanner.Err()
+func SignLinesEdDSA(r io.Reader, w io.Writer, privPEM []byte, opts ...Option) error {
+	return signLines(r, w, "EdDSA", privPEM, opts...)
 }
@@ -1,22 +1,46 @@
 package sign
 
 import (
+	"bufio"
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
 	crand "crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	jose "github.com/dvsekhvalnov/jose2go"
 )
 
+// init registers the algorithms jose2go lacks, so that the tests can
+// check tokens of every algorithm with jose.Decode. The package itself
+// leaves jose2go's registry alone.
+func init() {
+	jose.RegisterJws(&edDSAAlgorithm{})
+	jose.RegisterJws(&es256kAlgorithm{})
+}
+
+// testSecret is a 512-bit HMAC secret, long enough for HS256/HS384/HS512.
+var testSecret = []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
 func TestSignHS256AndLines(t *testing.T) {
-	secret := []byte("secret")
+	secret := testSecret[:32]
 	token, err := SignHS256(`{"a":1}`, secret)
 	if err != nil {
 		t.Fatalf("SignHS256 error: %v", err)
@@ -44,6 +68,53 @@ func TestSignHS256EmptySecret(t *testing.T) {
 	}
 }
 
+func TestSignHMACAlgorithms(t *testing.T) {
+	for _, alg := range []string{jose.HS256, jose.HS384, jose.HS512} {
+		t.Run(alg, func(t *testing.T) {
+			token, err := SignHMAC(`{"h":8}`, alg, testSecret)
+			if err != nil {
+				t.Fatalf("SignHMAC error: %v", err)
+			}
+			_, hdr, err := jose.Decode(token, testSecret)
+			if err != nil {
+				t.Fatalf("jose.Decode: %v", err)
+			}
+			if hdr["alg"] != alg {
+				t.Fatalf("expected alg=%s, got %v", alg, hdr["alg"])
+			}
+
+			var buf bytes.Buffer
+			if err := SignLinesHMAC(strings.NewReader("a\nb\n"), &buf, alg, testSecret); err != nil {
+				t.Fatalf("SignLinesHMAC error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignHMACKeyLength(t *testing.T) {
+	cases := []struct {
+		alg     string
+		size    int
+		message string
+	}{
+		{jose.HS256, 31, "at least 256 bits, got 248 bits"},
+		{jose.HS384, 32, "at least 384 bits, got 256 bits"},
+		{jose.HS512, 63, "at least 512 bits, got 504 bits"},
+	}
+	for _, tc := range cases {
+		_, err := SignHMAC("p", tc.alg, testSecret[:tc.size])
+		if !errors.Is(err, ErrWeakKey) || !strings.Contains(err.Error(), tc.message) {
+			t.Fatalf("%s: expected weak key error with %q, got %v", tc.alg, tc.message, err)
+		}
+		if _, err := SignHMAC("p", tc.alg, testSecret[:tc.size], AllowWeakKey()); err != nil {
+			t.Fatalf("%s: AllowWeakKey error: %v", tc.alg, err)
+		}
+	}
+	if _, err := SignHMAC("p", jose.RS256, testSecret); err == nil {
+		t.Fatalf("expected error for non-HMAC algorithm")
+	}
+}
+
 // --- RSA helpers ---
 
 func genRSAPrivatePEM(t *testing.T) []byte {
@@ -110,11 +181,89 @@ func TestSignLinesRS256BadKey(t *testing.T) {
 	}
 }
 
+// verifyRSA checks token's signature with crypto/rsa, independently of jose2go.
+func verifyRSA(t *testing.T, token string, pub *rsa.PublicKey, hash crypto.Hash, pss bool) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3-part JWT, got %d", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	if pss {
+		err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	} else {
+		err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+	}
+	if err != nil {
+		t.Fatalf("signature verification failed: %v", err)
+	}
+}
+
+func TestSignRSAAlgorithms(t *testing.T) {
+	privPEM := genRSAPrivatePEM(t)
+	block, _ := pem.Decode(privPEM)
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePKCS1PrivateKey: %v", err)
+	}
+
+	cases := []struct {
+		alg  string
+		hash crypto.Hash
+		pss  bool
+	}{
+		{jose.RS256, crypto.SHA256, false},
+		{jose.RS384, crypto.SHA384, false},
+		{jose.RS512, crypto.SHA512, false},
+		{jose.PS256, crypto.SHA256, true},
+		{jose.PS384, crypto.SHA384, true},
+		{jose.PS512, crypto.SHA512, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.alg, func(t *testing.T) {
+			token, err := SignRSA(`{"e":5}`, tc.alg, privPEM)
+			if err != nil {
+				t.Fatalf("SignRSA error: %v", err)
+			}
+			verifyRSA(t, token, &priv.PublicKey, tc.hash, tc.pss)
+
+			var buf bytes.Buffer
+			if err := SignLinesRSA(strings.NewReader("a\nb\n"), &buf, tc.alg, privPEM); err != nil {
+				t.Fatalf("SignLinesRSA error: %v", err)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				verifyRSA(t, line, &priv.PublicKey, tc.hash, tc.pss)
+			}
+		})
+	}
+}
+
+func TestSignRSAWrongAlg(t *testing.T) {
+	privPEM := genRSAPrivatePEM(t)
+	if _, err := SignRSA("p", jose.ES256, privPEM); err == nil {
+		t.Fatalf("expected error for non-RSA algorithm")
+	}
+	if err := SignLinesRSA(strings.NewReader("p"), &bytes.Buffer{}, "RS1", privPEM); err == nil {
+		t.Fatalf("expected error for unknown algorithm")
+	}
+}
+
 // --- ES256 helpers ---
 
 func genECPrivatePEM(t *testing.T) []byte {
 	t.Helper()
-	priv, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	return genECCurvePrivatePEM(t, elliptic.P256())
+}
+
+func genECCurvePrivatePEM(t *testing.T, curve elliptic.Curve) []byte {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(curve, crand.Reader)
 	if err != nil {
 		t.Fatalf("GenerateKey EC: %v", err)
 	}
@@ -147,6 +296,121 @@ func TestSignES256AndLines(t *testing.T) {
 	}
 }
 
+// genSecp256k1PrivatePEM returns a SEC 1 PEM secp256k1 key, preceded by an
+// "EC PARAMETERS" block like openssl ecparam -genkey writes it.
+func genSecp256k1PrivatePEM(t *testing.T) ([]byte, *secp256k1.PrivateKey) {
+	t.Helper()
+	priv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey secp256k1: %v", err)
+	}
+	params, err := asn1.Marshal(oidSecp256k1)
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	der, err := asn1.Marshal(sec1PrivateKey{
+		Version:       1,
+		PrivateKey:    priv.Serialize(),
+		NamedCurveOID: oidSecp256k1,
+	})
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	out := pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: params})
+	return append(out, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})...), priv
+}
+
+// splitJOSESignature returns the signing input and the raw R||S halves of token.
+func splitJOSESignature(t *testing.T, token string, size int) (input []byte, r, s *big.Int) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3-part JWT, got %d", len(parts))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	if len(sig) != 2*size {
+		t.Fatalf("expected %d-byte R||S signature, got %d", 2*size, len(sig))
+	}
+	return []byte(parts[0] + "." + parts[1]), new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+}
+
+func TestSignECAlgorithms(t *testing.T) {
+	cases := []struct {
+		alg   string
+		curve elliptic.Curve
+		hash  crypto.Hash
+		size  int
+	}{
+		{jose.ES256, elliptic.P256(), crypto.SHA256, 32},
+		{jose.ES384, elliptic.P384(), crypto.SHA384, 48},
+		{jose.ES512, elliptic.P521(), crypto.SHA512, 66},
+	}
+	for _, tc := range cases {
+		t.Run(tc.alg, func(t *testing.T) {
+			privPEM := genECCurvePrivatePEM(t, tc.curve)
+			block, _ := pem.Decode(privPEM)
+			priv, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				t.Fatalf("ParseECPrivateKey: %v", err)
+			}
+
+			token, err := SignEC(`{"f":6}`, tc.alg, privPEM)
+			if err != nil {
+				t.Fatalf("SignEC error: %v", err)
+			}
+			input, r, s := splitJOSESignature(t, token, tc.size)
+			h := tc.hash.New()
+			h.Write(input)
+			if !ecdsa.Verify(&priv.PublicKey, h.Sum(nil), r, s) {
+				t.Fatalf("signature verification failed")
+			}
+		})
+	}
+}
+
+func TestSignES256K(t *testing.T) {
+	privPEM, priv := genSecp256k1PrivatePEM(t)
+
+	var buf bytes.Buffer
+	if err := SignLinesEC(strings.NewReader("a\nb\n"), &buf, ES256K, privPEM); err != nil {
+		t.Fatalf("SignLinesEC error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(lines))
+	}
+	for _, token := range lines {
+		input, r, s := splitJOSESignature(t, token, 32)
+		var rs, ss secp256k1.ModNScalar
+		rs.SetByteSlice(r.Bytes())
+		ss.SetByteSlice(s.Bytes())
+		digest := sha256.Sum256(input)
+		if !secpecdsa.NewSignature(&rs, &ss).Verify(digest[:], priv.PubKey()) {
+			t.Fatalf("ES256K signature verification failed")
+		}
+	}
+}
+
+func TestSignECCurveMismatch(t *testing.T) {
+	p256 := genECPrivatePEM(t)
+	if _, err := SignEC("p", jose.ES384, p256); err == nil || !strings.Contains(err.Error(), "P-384") {
+		t.Fatalf("expected curve error for P-256 key with ES384, got %v", err)
+	}
+	if _, err := SignEC("p", ES256K, p256); err == nil {
+		t.Fatalf("expected error for P-256 key with ES256K")
+	}
+	k1, _ := genSecp256k1PrivatePEM(t)
+	if _, err := SignEC("p", jose.ES256, k1); err == nil {
+		t.Fatalf("expected error for secp256k1 key with ES256")
+	}
+	if _, err := SignEC("p", jose.RS256, p256); err == nil {
+		t.Fatalf("expected error for non-EC algorithm")
+	}
+}
+
 func TestSignES256BadKey(t *testing.T) {
 	_, err := SignES256("p", []byte("nope"))
 	if err == nil {
@@ -229,3 +493,67 @@ func TestSignLinesEdDSABadKey(t *testing.T) {
 		t.Fatalf("expected error for empty Ed25519 key")
 	}
 }
+
+// benchLines is the number of input lines per benchmark iteration.
+const benchLines = 10000
+
+func benchInput() []byte {
+	var b bytes.Buffer
+	for i := range benchLines {
+		fmt.Fprintf(&b, "{\"sub\":\"user-%d\",\"iat\":1700000000}\n", i)
+	}
+	return b.Bytes()
+}
+
+// signLinesUnbuffered is the output path SignLines used before it went
+// through lines.Process: one write per token, straight to w.
+func signLinesUnbuffered(r io.Reader, w io.Writer, s Signer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		tok, err := s.Sign([]byte(line))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, tok+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// BenchmarkSignLinesHS256 compares the unbuffered output path with
+// SignLines writing to a file, as the commands do with stdout. HS256 is
+// cheap enough for the output to dominate.
+func BenchmarkSignLinesHS256(b *testing.B) {
+	s, err := NewSigner(jose.HS256, testSecret[:32])
+	if err != nil {
+		b.Fatalf("NewSigner: %v", err)
+	}
+	input := benchInput()
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	defer out.Close()
+
+	b.Run("unbuffered", func(b *testing.B) {
+		for b.Loop() {
+			if err := signLinesUnbuffered(bytes.NewReader(input), out, s); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N*benchLines)/b.Elapsed().Seconds(), "tokens/s")
+	})
+	b.Run("buffered", func(b *testing.B) {
+		for b.Loop() {
+			if err := SignLines(bytes.NewReader(input), out, s, lines.Config{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.N*benchLines)/b.Elapsed().Seconds(), "tokens/s")
+	})
+}
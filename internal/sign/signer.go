@@ -0,0 +1,437 @@
+// SPDX-License-Identifier: MIT
+
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	jose "github.com/dvsekhvalnov/jose2go"
+)
+
+// Signer signs payloads as compact JWS using a fixed algorithm and a
+// parsed key.
+type Signer interface {
+	// Alg returns the JOSE "alg" header value.
+	Alg() string
+	// Sign signs a single payload and returns a compact JWS.
+	Sign(payload []byte) (string, error)
+	// SignWithHeaders is like Sign but adds headers to the protected
+	// header of this token only, replacing the signer's values for the
+	// same names. Setting "alg" is an error.
+	SignWithHeaders(payload []byte, headers map[string]any) (string, error)
+}
+
+// Algorithm describes a JWS algorithm known to the registry.
+type Algorithm struct {
+	// Name is the JOSE "alg" value, e.g. "RS256".
+	Name string
+	// KeyType is the JWK "kty" of keys used with the algorithm:
+	// "oct", "RSA", "EC" or "OKP".
+	KeyType string
+	// Curve is the JWK "crv" of "EC" and "OKP" keys used with the
+	// algorithm, e.g. "P-256"; empty for other key types.
+	Curve string
+	// ParseKey parses encoded key material (PEM, raw secret) into the
+	// in-memory key accepted by CheckKey. passphrase decrypts encrypted
+	// keys and is nil unless WithPassphrase is given.
+	ParseKey func(data, passphrase []byte) (any, error)
+	// CheckKey reports whether key can be used with the algorithm. Keys
+	// that are only too short are reported with an error wrapping
+	// ErrWeakKey, which AllowWeakKey overrides.
+	CheckKey func(key any) error
+	// JWS computes and verifies signatures.
+	JWS jose.JwsAlgorithm
+}
+
+var (
+	ErrUnknownAlg = errors.New("unknown signing algorithm")
+	ErrWeakKey    = errors.New("key is too short")
+	// ErrAlgHeader names the -override-alg flag of the commands, which
+	// callers of this package know as OverrideAlg.
+	ErrAlgHeader = errors.New(`"alg" header is set by the signer, use -override-alg to change it`)
+)
+
+// Option configures a Signer created by NewSigner or ParseSigner.
+type Option func(*signerOptions)
+
+type signerOptions struct {
+	allowWeakKey bool
+	headers      map[string]any
+	overrideAlg  string
+	kidThumb     bool
+	passphrase   []byte
+	lines        lines.Config
+}
+
+// AllowWeakKey accepts keys that fail a CheckKey with ErrWeakKey, e.g. HMAC
+// secrets shorter than the hash output. Meant for negative testing only.
+func AllowWeakKey() Option {
+	return func(o *signerOptions) { o.allowWeakKey = true }
+}
+
+// WithHeader adds a protected header parameter such as "kid", "typ" or
+// "cty" to every token. Setting "alg" is an error, see OverrideAlg.
+func WithHeader(name string, value any) Option {
+	return func(o *signerOptions) {
+		if o.headers == nil {
+			o.headers = map[string]any{}
+		}
+		o.headers[name] = value
+	}
+}
+
+// WithHeaders adds all given protected header parameters, see WithHeader.
+func WithHeaders(headers map[string]any) Option {
+	return func(o *signerOptions) {
+		for name, value := range headers {
+			WithHeader(name, value)(o)
+		}
+	}
+}
+
+// WithThumbprintKid sets the "kid" header to the RFC 7638 SHA-256 JWK
+// thumbprint of the signing key's public part, see jwk.Thumbprint. HMAC
+// secrets have no public part, so NewSigner rejects them, as well as a kid
+// set with WithHeader.
+func WithThumbprintKid() Option {
+	return func(o *signerOptions) { o.kidThumb = true }
+}
+
+// OverrideAlg writes alg into the "alg" header instead of the signing
+// algorithm's name. The signature is still computed with the signing
+// algorithm, so the resulting tokens are meant for negative testing.
+func OverrideAlg(alg string) Option {
+	return func(o *signerOptions) { o.overrideAlg = alg }
+}
+
+// WithPassphrase decrypts an encrypted PKCS8 key in ParseSigner.
+func WithPassphrase(passphrase []byte) Option {
+	return func(o *signerOptions) { o.passphrase = passphrase }
+}
+
+// WithLines sets how the SignLinesX helpers process their input, e.g. the
+// number of workers. NewSigner and ParseSigner ignore it.
+func WithLines(cfg lines.Config) Option {
+	return func(o *signerOptions) { o.lines = cfg }
+}
+
+// registry maps JOSE algorithm names to algorithms. Like jose2go's own
+// registry it is populated from init functions and not guarded by a lock.
+var registry = map[string]Algorithm{}
+
+func init() {
+	for _, a := range []*hmacAlgorithm{
+		{name: jose.HS256, hash: crypto.SHA256},
+		{name: jose.HS384, hash: crypto.SHA384},
+		{name: jose.HS512, hash: crypto.SHA512},
+	} {
+		Register(Algorithm{Name: a.name, KeyType: "oct", ParseKey: parseSecret, CheckKey: checkSecret(a.name), JWS: a})
+	}
+	for _, a := range []*rsaAlgorithm{
+		{name: jose.RS256, hash: crypto.SHA256},
+		{name: jose.RS384, hash: crypto.SHA384},
+		{name: jose.RS512, hash: crypto.SHA512},
+		{name: jose.PS256, hash: crypto.SHA256, pss: true},
+		{name: jose.PS384, hash: crypto.SHA384, pss: true},
+		{name: jose.PS512, hash: crypto.SHA512, pss: true},
+	} {
+		Register(Algorithm{Name: a.name, KeyType: "RSA", ParseKey: parsePrivateKey, CheckKey: checkRSAPrivateKey, JWS: a})
+	}
+	for _, a := range []struct {
+		jws   *ecdsaAlgorithm
+		curve string
+	}{
+		{&ecdsaAlgorithm{name: jose.ES256, hash: crypto.SHA256, size: 32}, "P-256"},
+		{&ecdsaAlgorithm{name: jose.ES384, hash: crypto.SHA384, size: 48}, "P-384"},
+		{&ecdsaAlgorithm{name: jose.ES512, hash: crypto.SHA512, size: 66}, "P-521"},
+	} {
+		Register(Algorithm{Name: a.jws.name, KeyType: "EC", Curve: a.curve, ParseKey: parsePrivateKey, CheckKey: checkECPrivateKey(a.curve), JWS: a.jws})
+	}
+	Register(Algorithm{
+		Name:     ES256K,
+		KeyType:  "EC",
+		Curve:    "secp256k1",
+		ParseKey: parsePrivateKey,
+		CheckKey: checkECPrivateKey("secp256k1"),
+		JWS:      &es256kAlgorithm{},
+	})
+	Register(Algorithm{
+		Name:     "EdDSA",
+		KeyType:  "OKP",
+		Curve:    "Ed25519",
+		ParseKey: parsePrivateKey,
+		CheckKey: checkEdPrivateKey,
+		JWS:      &edDSAAlgorithm{},
+	})
+}
+
+// AlgorithmsFor returns the names of registered algorithms that use keys
+// of the given JWK key type, sorted.
+func AlgorithmsFor(keyType string) []string {
+	var names []string
+	for _, name := range Algorithms() {
+		if registry[name].KeyType == keyType {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Register adds an algorithm to the registry, replacing any algorithm
+// with the same name. Algorithms without a JWS are rejected by NewSigner.
+// Register is meant to be called from init functions.
+func Register(a Algorithm) {
+	registry[a.Name] = a
+}
+
+// Lookup returns the registered algorithm with the given JOSE name.
+func Lookup(name string) (Algorithm, bool) {
+	a, ok := registry[name]
+	return a, ok
+}
+
+// Algorithms returns the names of all registered algorithms, sorted.
+func Algorithms() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joseSigner is a Signer producing compact JWS with a fixed, pre-encoded
+// protected header.
+type joseSigner struct {
+	alg     string
+	jws     jose.JwsAlgorithm
+	key     any
+	headers map[string]any // protected header, merged in SignWithHeaders
+	header  string         // base64url-encoded protected header
+}
+
+func (s *joseSigner) Alg() string { return s.alg }
+
+func (s *joseSigner) Sign(payload []byte) (string, error) {
+	return s.sign(s.header, payload)
+}
+
+func (s *joseSigner) SignWithHeaders(payload []byte, headers map[string]any) (string, error) {
+	if len(headers) == 0 {
+		return s.Sign(payload)
+	}
+	merged := make(map[string]any, len(s.headers)+len(headers))
+	for name, value := range s.headers {
+		merged[name] = value
+	}
+	for name, value := range headers {
+		if name == "alg" {
+			return "", ErrAlgHeader
+		}
+		merged[name] = value
+	}
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return "", fmt.Errorf("encode header: %w", err)
+	}
+	return s.sign(base64.RawURLEncoding.EncodeToString(encoded), payload)
+}
+
+func (s *joseSigner) sign(header string, payload []byte) (string, error) {
+	input := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := s.jws.Sign([]byte(input), s.key)
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// NewSigner returns a Signer for the named algorithm and an in-memory key,
+// e.g. *rsa.PrivateKey for RS256 or []byte for HS256.
+func NewSigner(alg string, key any, opts ...Option) (Signer, error) {
+	a, ok := Lookup(alg)
+	if !ok || a.JWS == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlg, alg)
+	}
+	var o signerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := a.CheckKey(key); err != nil && !(o.allowWeakKey && errors.Is(err, ErrWeakKey)) {
+		return nil, err
+	}
+
+	header := map[string]any{}
+	for name, value := range o.headers {
+		if name == "alg" {
+			return nil, ErrAlgHeader
+		}
+		header[name] = value
+	}
+	if o.kidThumb {
+		if _, ok := key.([]byte); ok {
+			// RFC 7638 section 7: the thumbprint of a symmetric key
+			// reveals information about the key.
+			return nil, errors.New("kid thumbprint: not available for HMAC secrets, set a kid instead")
+		}
+		if _, ok := header["kid"]; ok {
+			return nil, errors.New("kid thumbprint: a kid header is set already")
+		}
+		kid, err := jwk.Thumbprint(key)
+		if err != nil {
+			return nil, fmt.Errorf("kid thumbprint: %w", err)
+		}
+		header["kid"] = kid
+	}
+	header["alg"] = alg
+	if o.overrideAlg != "" {
+		header["alg"] = o.overrideAlg
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encode header: %w", err)
+	}
+
+	return &joseSigner{
+		alg:     alg,
+		jws:     a.JWS,
+		key:     key,
+		headers: header,
+		header:  base64.RawURLEncoding.EncodeToString(encoded),
+	}, nil
+}
+
+// ParseSigner parses encoded key material and returns a Signer for it.
+// keyData is either what the algorithm's ParseKey accepts (PEM, raw secret;
+// encrypted PKCS8 needs WithPassphrase) or a private JWK or JWK Set in JSON.
+// From a JWK Set the key is selected by the "kid" header (see WithHeader);
+// without one, the only key usable with the algorithm is taken. A JWK's kid
+// is put into the header unless a kid is set explicitly or
+// WithThumbprintKid is given.
+func ParseSigner(alg string, keyData []byte, opts ...Option) (Signer, error) {
+	a, ok := Lookup(alg)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlg, alg)
+	}
+	var o signerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// HMAC secrets may start with "{" too, so they are only taken for a
+	// JWK when they are one.
+	isJWK := jwk.IsJSON(keyData)
+	if a.KeyType == "oct" {
+		isJWK = jwk.IsJWK(keyData)
+	}
+	if isJWK {
+		key, kid, err := parseJWK(a, keyData, &o)
+		if err != nil {
+			return nil, err
+		}
+		if kid != "" && !o.kidThumb {
+			opts = append([]Option{WithHeader("kid", kid)}, opts...)
+		}
+		return NewSigner(alg, key, opts...)
+	}
+	key, err := a.ParseKey(keyData, o.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(alg, key, opts...)
+}
+
+// parseJWK selects a private key for algorithm a from a JWK or JWK Set and
+// returns it with its kid.
+func parseJWK(a Algorithm, data []byte, o *signerOptions) (any, string, error) {
+	set, err := jwk.ParseSet(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kid, _ := o.headers["kid"].(string)
+	k, err := set.SelectOne(a.KeyType, a.Name, "sig", kid)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := k.PrivateKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("JWK %q: %w", k.Kid, err)
+	}
+	return key, k.Kid, nil
+}
+
+// SignLines reads non-empty lines from r, signs each line with s,
+// and writes resulting JWTs to w. cfg selects the input format, the number
+// of workers, the output order, how lines become payloads and what
+// happens to lines that fail, see lines.Config. The header of a
+// jsonl-envelope line is added to its token, see SignWithHeaders.
+func SignLines(r io.Reader, w io.Writer, s Signer, cfg lines.Config) error {
+	return lines.ProcessRecords(r, w, cfg, func(_ int, payload []byte, header map[string]any) (string, error) {
+		return s.SignWithHeaders(payload, header)
+	})
+}
+
+// -----------------------------------------------------------------------------
+// Key checks
+// -----------------------------------------------------------------------------
+
+// checkSecret returns a key check for the named HMAC algorithm. RFC 7518
+// section 3.2 requires a key at least as long as the hash output, i.e.
+// the number in the algorithm name.
+func checkSecret(alg string) func(key any) error {
+	var minBits int
+	fmt.Sscanf(alg, "HS%d", &minBits)
+	return func(key any) error {
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("expected []byte secret, got %T", key)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("secret must not be empty")
+		}
+		if bits := len(secret) * 8; bits < minBits {
+			return fmt.Errorf("%w: %s requires a secret of at least %d bits, got %d bits", ErrWeakKey, alg, minBits, bits)
+		}
+		return nil
+	}
+}
+
+func checkRSAPrivateKey(key any) error {
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		return fmt.Errorf("expected *rsa.PrivateKey, got %T", key)
+	}
+	return nil
+}
+
+// checkECPrivateKey returns a key check accepting EC private keys on the
+// named curve only, so that e.g. a P-256 key is rejected for ES384.
+func checkECPrivateKey(curve string) func(key any) error {
+	return func(key any) error {
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("expected *ecdsa.PrivateKey, got %T", key)
+		}
+		if got := priv.Curve.Params().Name; got != curve {
+			return fmt.Errorf("expected EC key on curve %s, got %s", curve, got)
+		}
+		return nil
+	}
+}
+
+func checkEdPrivateKey(key any) error {
+	if _, ok := key.(ed25519.PrivateKey); !ok {
+		return fmt.Errorf("expected ed25519.PrivateKey, got %T", key)
+	}
+	return nil
+}
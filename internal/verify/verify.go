@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: MIT
+
+// Package verify checks compact JWS tokens against public keys, JWK Sets
+// or HMAC secrets, and validates the exp, nbf, iss and aud claims.
+//
+// Signatures are checked with the algorithms of the sign package
+// registry, so every algorithm that can be signed can also be verified.
+package verify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/keys"
+	"github.com/danilkiff/jwt-token-generator/internal/lines"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+)
+
+var (
+	ErrMalformed   = errors.New("malformed token")
+	ErrAlgorithm   = errors.New("algorithm not accepted")
+	ErrNoKey       = errors.New("no key for token")
+	ErrSignature   = errors.New("signature verification failed")
+	ErrExpired     = errors.New("token is expired")
+	ErrNotYetValid = errors.New("token is not valid yet")
+	ErrIssuer      = errors.New("issuer mismatch")
+	ErrAudience    = errors.New("audience mismatch")
+	ErrClaims      = errors.New("invalid claims")
+)
+
+// Config controls which tokens a Verifier accepts.
+type Config struct {
+	// Algorithms lists the accepted "alg" values; empty accepts every
+	// registered algorithm usable with the keys.
+	Algorithms []string
+	// Issuer, when set, must equal the "iss" claim.
+	Issuer string
+	// Audience, when set, must share a value with the "aud" claim.
+	Audience []string
+	// Leeway is the clock skew allowed when checking exp and nbf.
+	Leeway time.Duration
+	// Now returns the current time; defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verifier checks tokens with a fixed set of keys.
+type Verifier struct {
+	// set holds the JWK metadata used to select a key, keys the parsed
+	// key of each of its members.
+	set  jwk.Set
+	keys map[*jwk.Key]any
+	cfg  Config
+}
+
+// New returns a Verifier for keyData, which is a PEM public key (PKIX or
+// PKCS1), a JWK or a JWK Set. Keys in a JWK Set are selected by the token's
+// "kid" header, or by key type when the token has none.
+func New(keyData []byte, cfg Config) (*Verifier, error) {
+	if !jwk.IsJSON(keyData) {
+		key, err := keys.ParsePublicKey(keyData)
+		if err != nil {
+			return nil, err
+		}
+		return newVerifier(key, cfg)
+	}
+	set, err := jwk.ParseSet(keyData)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{set: *set, keys: make(map[*jwk.Key]any), cfg: cfg}
+	for i := range v.set.Keys {
+		k := &v.set.Keys[i]
+		key, err := k.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWK %d (kid %q): %w", i, k.Kid, err)
+		}
+		v.keys[k] = key
+	}
+	return v, nil
+}
+
+// NewHMAC returns a Verifier for tokens signed with an HMAC secret.
+func NewHMAC(secret []byte, cfg Config) (*Verifier, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+	return newVerifier(append([]byte(nil), secret...), cfg)
+}
+
+func newVerifier(key any, cfg Config) (*Verifier, error) {
+	meta, err := jwk.FromPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{set: jwk.Set{Keys: []jwk.Key{*meta}}, cfg: cfg}
+	v.keys = map[*jwk.Key]any{&v.set.Keys[0]: key}
+	return v, nil
+}
+
+// Header is the part of the JOSE header used for verification.
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Verify checks the signature and claims of a compact JWS and returns its
+// header. Errors wrap one of the package's Err values.
+func (v *Verifier) Verify(token string) (*Header, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 segments, got %d", ErrMalformed, len(parts))
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	var hdr Header
+	if err := json.Unmarshal(rawHeader, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformed, err)
+	}
+
+	a, ok := sign.Lookup(hdr.Alg)
+	if !ok || (len(v.cfg.Algorithms) > 0 && !slices.Contains(v.cfg.Algorithms, hdr.Alg)) {
+		return &hdr, fmt.Errorf("%w: %q", ErrAlgorithm, hdr.Alg)
+	}
+	candidates := v.set.Select(a.KeyType, a.Curve, a.Name, "sig", hdr.Kid)
+	if len(candidates) == 0 {
+		return &hdr, fmt.Errorf("%w: alg %s, kid %q", ErrNoKey, hdr.Alg, hdr.Kid)
+	}
+	input := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range candidates {
+		if a.JWS.Verify(input, sig, v.keys[k]) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return &hdr, ErrSignature
+	}
+	return &hdr, v.checkClaims(payload)
+}
+
+// checkClaims validates the registered time, issuer and audience claims.
+func (v *Verifier) checkClaims(payload []byte) error {
+	var claims struct {
+		Iss *string         `json:"iss"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *json.Number    `json:"exp"`
+		Nbf *json.Number    `json:"nbf"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return fmt.Errorf("%w: %v", ErrClaims, err)
+	}
+
+	now := time.Now()
+	if v.cfg.Now != nil {
+		now = v.cfg.Now()
+	}
+	if claims.Exp != nil {
+		exp, err := numericDate("exp", *claims.Exp)
+		if err != nil {
+			return err
+		}
+		if !now.Before(exp.Add(v.cfg.Leeway)) {
+			return fmt.Errorf("%w: exp %s", ErrExpired, exp.UTC().Format(time.RFC3339))
+		}
+	}
+	if claims.Nbf != nil {
+		nbf, err := numericDate("nbf", *claims.Nbf)
+		if err != nil {
+			return err
+		}
+		if now.Add(v.cfg.Leeway).Before(nbf) {
+			return fmt.Errorf("%w: nbf %s", ErrNotYetValid, nbf.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if v.cfg.Issuer != "" && (claims.Iss == nil || *claims.Iss != v.cfg.Issuer) {
+		got := "<missing>"
+		if claims.Iss != nil {
+			got = *claims.Iss
+		}
+		return fmt.Errorf("%w: got %q", ErrIssuer, got)
+	}
+	if len(v.cfg.Audience) > 0 {
+		aud, err := audience(claims.Aud)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(v.cfg.Audience, a) }) {
+			return fmt.Errorf("%w: got %q", ErrAudience, aud)
+		}
+	}
+	return nil
+}
+
+// numericDate converts a NumericDate claim (RFC 7519 section 2).
+func numericDate(name string, n json.Number) (time.Time, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s is not a number", ErrClaims, name)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+// audience decodes an "aud" claim, which is a string or an array of
+// strings.
+func audience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("%w: aud is neither a string nor an array of strings", ErrClaims)
+	}
+	return many, nil
+}
+
+// Result is the outcome of verifying one input line.
+type Result struct {
+	Line  int    `json:"line"`
+	Valid bool   `json:"valid"`
+	Alg   string `json:"alg,omitempty"`
+	Kid   string `json:"kid,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// VerifyLines verifies each non-empty line from r and writes one JSON
+// Result per token to w. Lines longer than maxLineBytes
+// (lines.DefaultMaxLineBytes when zero) fail verification. It returns the
+// number of tokens that failed verification.
+func VerifyLines(r io.Reader, w io.Writer, v *Verifier, maxLineBytes int) (int, error) {
+	lr := lines.NewReader(r, maxLineBytes)
+	enc := json.NewEncoder(w)
+	failed := 0
+	for {
+		raw, err := lr.Next()
+		if err == io.EOF {
+			return failed, nil
+		}
+		res := Result{Line: lr.Line(), Valid: true}
+		var tooLong *lines.LineTooLongError
+		switch {
+		case errors.As(err, &tooLong):
+			err = tooLong
+		case err != nil:
+			return failed, err
+		default:
+			line := strings.TrimSpace(string(raw))
+			if line == "" {
+				continue
+			}
+			var hdr *Header
+			hdr, err = v.Verify(line)
+			if hdr != nil {
+				res.Alg, res.Kid = hdr.Alg, hdr.Kid
+			}
+		}
+		if err != nil {
+			res.Valid, res.Error = false, err.Error()
+			failed++
+		}
+		if err := enc.Encode(res); err != nil {
+			return failed, err
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danilkiff/jwt-token-generator/internal/jwk"
+	"github.com/danilkiff/jwt-token-generator/internal/sign"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+func mustSign(t *testing.T, alg string, key any, payload string, opts ...sign.Option) string {
+	t.Helper()
+	s, err := sign.NewSigner(alg, key, opts...)
+	if err != nil {
+		t.Fatalf("NewSigner %s: %v", alg, err)
+	}
+	token, err := s.Sign([]byte(payload))
+	if err != nil {
+		t.Fatalf("Sign %s: %v", alg, err)
+	}
+	return token
+}
+
+func publicPEM(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey RSA: %v", err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey EC: %v", err)
+	}
+	k1, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey secp256k1: %v", err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey Ed25519: %v", err)
+	}
+
+	pemCases := []struct {
+		alg  string
+		priv any
+		pub  []byte
+	}{
+		{"RS256", rsaKey, publicPEM(t, &rsaKey.PublicKey)},
+		{"PS512", rsaKey, publicPEM(t, &rsaKey.PublicKey)},
+		{"ES384", p384, publicPEM(t, &p384.PublicKey)},
+		{"EdDSA", edPriv, publicPEM(t, edPub)},
+	}
+	for _, tc := range pemCases {
+		v, err := New(tc.pub, Config{})
+		if err != nil {
+			t.Fatalf("%s: New: %v", tc.alg, err)
+		}
+		hdr, err := v.Verify(mustSign(t, tc.alg, tc.priv, `{"sub":"a"}`))
+		if err != nil {
+			t.Fatalf("%s: Verify: %v", tc.alg, err)
+		}
+		if hdr.Alg != tc.alg {
+			t.Fatalf("%s: unexpected alg %q", tc.alg, hdr.Alg)
+		}
+	}
+
+	// secp256k1 has no PKIX encoding in crypto/x509, so use a JWK.
+	pub, err := jwk.FromPublicKey(k1.ToECDSA())
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	data, err := json.Marshal(pub)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	v, err := New(data, Config{})
+	if err != nil {
+		t.Fatalf("New JWK: %v", err)
+	}
+	if _, err := v.Verify(mustSign(t, sign.ES256K, k1.ToECDSA(), `{}`)); err != nil {
+		t.Fatalf("ES256K Verify: %v", err)
+	}
+
+	for _, alg := range []string{"HS256", "HS384", "HS512"} {
+		v, err := NewHMAC(testSecret, Config{})
+		if err != nil {
+			t.Fatalf("NewHMAC: %v", err)
+		}
+		if _, err := v.Verify(mustSign(t, alg, testSecret, `{}`)); err != nil {
+			t.Fatalf("%s: Verify: %v", alg, err)
+		}
+		if _, err := v.Verify(mustSign(t, alg, append([]byte("x"), testSecret...), `{}`)); !errors.Is(err, ErrSignature) {
+			t.Fatalf("%s: expected ErrSignature for other secret, got %v", alg, err)
+		}
+	}
+}
+
+func TestVerifyKeySelection(t *testing.T) {
+	k1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	k2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var set jwk.Set
+	for kid, key := range map[string]*ecdsa.PrivateKey{"one": k1, "two": k2} {
+		k, err := jwk.FromPublicKey(key)
+		if err != nil {
+			t.Fatalf("FromPublicKey: %v", err)
+		}
+		k.Kid = kid
+		set.Keys = append(set.Keys, *k)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	v, err := New(data, Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	hdr, err := v.Verify(mustSign(t, "ES256", k2, `{}`, sign.WithHeader("kid", "two")))
+	if err != nil || hdr.Kid != "two" {
+		t.Fatalf("Verify with kid: %v, %+v", err, hdr)
+	}
+	if _, err := v.Verify(mustSign(t, "ES256", k1, `{}`)); err != nil {
+		t.Fatalf("Verify without kid: %v", err)
+	}
+	if _, err := v.Verify(mustSign(t, "ES256", k1, `{}`, sign.WithHeader("kid", "two"))); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature for wrong kid, got %v", err)
+	}
+	if _, err := v.Verify(mustSign(t, "HS256", testSecret, `{}`)); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for HS256 against EC keys, got %v", err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if _, err := v.Verify(mustSign(t, "ES384", p384, `{}`)); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey for ES384 against P-256 keys, got %v", err)
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cfg := Config{
+		Issuer:   "https://issuer.example",
+		Audience: []string{"api"},
+		Leeway:   30 * time.Second,
+		Now:      func() time.Time { return now },
+	}
+	v, err := NewHMAC(testSecret, cfg)
+	if err != nil {
+		t.Fatalf("NewHMAC: %v", err)
+	}
+
+	cases := []struct {
+		claims string
+		want   error
+	}{
+		{`{"iss":"https://issuer.example","aud":"api","exp":1700000060,"nbf":1699999990}`, nil},
+		{`{"iss":"https://issuer.example","aud":["web","api"]}`, nil},
+		{`{"iss":"https://issuer.example","aud":"api","exp":1699999980}`, nil},
+		{`{"iss":"https://issuer.example","aud":"api","exp":1699999970}`, ErrExpired},
+		{`{"iss":"https://issuer.example","aud":"api","nbf":1700000020.5}`, nil},
+		{`{"iss":"https://issuer.example","aud":"api","nbf":1700000031}`, ErrNotYetValid},
+		{`{"iss":"https://other.example","aud":"api"}`, ErrIssuer},
+		{`{"aud":"api"}`, ErrIssuer},
+		{`{"iss":"https://issuer.example","aud":["web"]}`, ErrAudience},
+		{`{"iss":"https://issuer.example"}`, ErrAudience},
+		{`{"iss":"https://issuer.example","aud":1}`, ErrClaims},
+		{`{"exp":"soon"}`, ErrClaims},
+		{`not json`, ErrClaims},
+	}
+	for _, tc := range cases {
+		_, err := v.Verify(mustSign(t, "HS256", testSecret, tc.claims))
+		if tc.want == nil && err != nil || tc.want != nil && !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.claims, tc.want, err)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	v, err := NewHMAC(testSecret, Config{Algorithms: []string{"HS512"}})
+	if err != nil {
+		t.Fatalf("NewHMAC: %v", err)
+	}
+	if _, err := v.Verify(mustSign(t, "HS256", testSecret, `{}`)); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("expected ErrAlgorithm for HS256 outside allow list, got %v", err)
+	}
+	// "none" is not a registered algorithm.
+	if _, err := v.Verify("eyJhbGciOiJub25lIn0.e30."); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("expected ErrAlgorithm for alg none, got %v", err)
+	}
+	for _, token := range []string{"a.b", "!!.e30.", "e30.!!.", "e30.e30.!!", "bm90IGpzb24.e30."} {
+		if _, err := v.Verify(token); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("%q: expected ErrMalformed, got %v", token, err)
+		}
+	}
+	if _, err := NewHMAC(nil, Config{}); err == nil {
+		t.Fatalf("expected error for empty secret")
+	}
+	if _, err := New([]byte("not a key"), Config{}); err == nil {
+		t.Fatalf("expected error for bad key data")
+	}
+	if _, err := New([]byte(`{"kty":"RSA","n":"AQ"}`), Config{}); err == nil {
+		t.Fatalf("expected error for invalid JWK")
+	}
+}
+
+func TestVerifyLines(t *testing.T) {
+	v, err := NewHMAC(testSecret, Config{})
+	if err != nil {
+		t.Fatalf("NewHMAC: %v", err)
+	}
+	good := mustSign(t, "HS256", testSecret, `{}`, sign.WithHeader("kid", "k"))
+	forged := mustSign(t, "HS256", append([]byte("x"), testSecret...), `{}`)
+	in := good + "\n\n" + forged + "\nbroken\n"
+
+	var out bytes.Buffer
+	failed, err := VerifyLines(strings.NewReader(in), &out, v, 0)
+	if err != nil {
+		t.Fatalf("VerifyLines: %v", err)
+	}
+	if failed != 2 {
+		t.Fatalf("expected 2 failures, got %d", failed)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 results, got %d: %s", len(lines), out.String())
+	}
+	if lines[0] != `{"line":1,"valid":true,"alg":"HS256","kid":"k"}` {
+		t.Fatalf("unexpected result: %s", lines[0])
+	}
+	var res Result
+	if err := json.Unmarshal([]byte(lines[1]), &res); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if res.Line != 3 || res.Valid || res.Alg != "HS256" || res.Error != ErrSignature.Error() {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	out.Reset()
+	in = strings.Repeat("x", 200) + "\n" + good + "\n"
+	if failed, err = VerifyLines(strings.NewReader(in), &out, v, 150); err != nil || failed != 1 {
+		t.Fatalf("VerifyLines with long line: %d, %v", failed, err)
+	}
+	want := `{"line":1,"valid":false,"error":"200 bytes exceeds the maximum line size of 150 bytes"}` + "\n" +
+		`{"line":2,"valid":true,"alg":"HS256","kid":"k"}` + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output for long line: %s", out.String())
+	}
+}